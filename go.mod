@@ -2,6 +2,9 @@ module github.com/tvrzna/emptty
 
 go 1.14
 
-require github.com/msteinert/pam v0.0.0-20200810204841-913b8f8cdf8b
+require (
+	github.com/godbus/dbus/v5 v5.1.0
+	github.com/msteinert/pam v0.0.0-20200810204841-913b8f8cdf8b
+)
 
 replace github.com/tvrzna/emptty/src => ./src
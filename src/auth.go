@@ -0,0 +1,229 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/syslog"
+	"os"
+	"os/user"
+	"syscall"
+)
+
+const (
+	constAuthPam    = "pam"
+	constAuthShadow = "shadow"
+
+	pathAutologinMarker = "/run/emptty/autologin-tty%s"
+
+	// autologinDelayTick defines in tenths of second, how long is waited for pressed key in each step of countdown
+	autologinDelayTick = 10
+)
+
+// authenticator defines backend, that authenticates user and handles his session.
+type authenticator interface {
+	// Authenticates user, it returns name of user, that tried to log in.
+	authenticate(conf *config) (string, error)
+
+	// Prepares session of default user without authentication.
+	autologin(conf *config) error
+
+	// Opens session of authenticated user.
+	openSession(conf *config) error
+
+	// Closes session of authenticated user.
+	closeSession() error
+
+	// Gets environmental variables defined by backend.
+	getEnvList() map[string]string
+}
+
+// Creates authenticator according to AUTH_BACKEND.
+func newAuthenticator(conf *config) (authenticator, error) {
+	switch conf.authBackend {
+	case constAuthPam:
+		return newPamAuth()
+	case constAuthShadow:
+		return &shadowAuth{}, nil
+	}
+	return nil, errors.New("Unknown authentication backend: " + conf.authBackend)
+}
+
+// Handles authentication of user.
+// If user is successfully authorized, it returns sysuser.
+// If authentication fails, user is prompted again until MAX_LOGIN_ATTEMPTS is reached.
+// Failed attempts are counted across login loop, until user is successfully authenticated.
+//
+// If autologin is enabled and allowed, authentication is skipped and only session of default user is opened.
+func authUser(conf *config, auth authenticator) *sysuser {
+	var username string
+	var err error
+
+	if conf.autologin {
+		if isAutologinAllowed(conf, fmt.Sprintf(pathAutologinMarker, conf.strTTY())) && waitAutologinDelay(conf) {
+			err = auth.autologin(conf)
+			handleErr(err)
+			auditAutologin(conf)
+			return openAuthSession(conf, auth, conf.defaultUser)
+		}
+		// Selection of desktop behaves as without autologin
+		conf.autologin = false
+	}
+
+	for {
+		username, err = auth.authenticate(conf)
+		if err == nil {
+			break
+		}
+		if err == errInterrupted {
+			handleInterruptErr(err)
+		}
+		addBtmpEntry(username, os.Getpid(), conf.strTTY())
+		failedLoginAttempts++
+		handleLoginFailure(conf, failedLoginAttempts, err)
+	}
+	failedLoginAttempts = 0
+	log.Print("Authenticate OK")
+
+	return openAuthSession(conf, auth, username)
+}
+
+// Opens session of authorized user and returns sysuser.
+func openAuthSession(conf *config, auth authenticator, username string) *sysuser {
+	err := auth.openSession(conf)
+	if err == errInterrupted {
+		handleInterruptErr(err)
+	}
+	handleErr(err)
+
+	usr, err := user.Lookup(username)
+	handleErr(err)
+
+	return getSysuser(usr)
+}
+
+// Records autologin of default user into emptty log and into system log with authpriv facility,
+// so the record is kept even after emptty log is rotated.
+func auditAutologin(conf *config) {
+	msg := fmt.Sprintf("Autologin of user '%s' on tty%s", conf.defaultUser, conf.strTTY())
+	log.Print(msg)
+
+	w, err := syslog.New(syslog.LOG_AUTHPRIV|syslog.LOG_NOTICE, "emptty")
+	if err != nil {
+		log.Print("Could not write autologin into system log: ", err)
+		return
+	}
+	defer w.Close()
+	if err := w.Notice(msg); err != nil {
+		log.Print("Could not write autologin into system log: ", err)
+	}
+}
+
+// Checks, if autologin of default user is allowed. Default user has to be member of AUTOLOGIN_GROUP.
+// If AUTOLOGIN_ONCE is enabled, autologin is allowed only, if marker does not exist yet; the marker is created then.
+func isAutologinAllowed(conf *config, markerPath string) bool {
+	if conf.defaultUser == "" {
+		return false
+	}
+	if !isUserInGroup(conf.defaultUser, conf.autologinGroup) {
+		log.Printf("Autologin denied, user '%s' is not member of group '%s'", conf.defaultUser, conf.autologinGroup)
+		return false
+	}
+	if conf.autologinOnce && !createAutologinMarker(markerPath) {
+		log.Print("Autologin skipped, it was already used since boot")
+		return false
+	}
+	return true
+}
+
+// Shows countdown of AUTOLOGIN_DELAY, any pressed key cancels autologin.
+// It returns true, if autologin should continue.
+func waitAutologinDelay(conf *config) bool {
+	if conf.autologinDelay <= 0 {
+		return true
+	}
+
+	fd := os.Stdin.Fd()
+	original, err := getTermios(fd)
+	if err != nil {
+		log.Print(err)
+		return true
+	}
+
+	// Read returns after one tick even without any pressed key
+	raw := *original
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO
+	raw.Cc[syscall.VMIN] = 0
+	raw.Cc[syscall.VTIME] = autologinDelayTick
+	if err := setTermios(fd, &raw); err != nil {
+		log.Print(err)
+		return true
+	}
+	defer setTermios(fd, original)
+
+	return countdownAutologin(os.Stdout, func(buf []byte) (int, error) {
+		return syscall.Read(int(fd), buf)
+	}, conf.defaultUser, conf.autologinDelay)
+}
+
+// Prints countdown into w, each step waits for pressed key by read.
+// It returns false, if any key was pressed.
+func countdownAutologin(w io.Writer, read func([]byte) (int, error), username string, delay int) bool {
+	buf := make([]byte, 32)
+	for remaining := delay; remaining > 0; remaining-- {
+		fmt.Fprintf(w, "\rAutologin of %s in %d s, press any key to cancel ", username, remaining)
+		if n, _ := read(buf); n > 0 {
+			fmt.Fprintln(w)
+			log.Print("Autologin cancelled")
+			return false
+		}
+	}
+	fmt.Fprintln(w)
+	return true
+}
+
+// Checks, if user is member of group.
+func isUserInGroup(username string, groupname string) bool {
+	usr, err := user.Lookup(username)
+	if err != nil {
+		return false
+	}
+	grp, err := user.LookupGroup(groupname)
+	if err != nil {
+		return false
+	}
+	gids, err := usr.GroupIds()
+	if err != nil {
+		return false
+	}
+	return contains(gids, grp.Gid)
+}
+
+// Creates marker of used autologin, it returns false, if marker already exists or could not be created.
+func createAutologinMarker(path string) bool {
+	if err := mkDirsForFile(path, 0755); err != nil {
+		log.Print(err)
+		return false
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+// Handles close of authentication
+func closeAuth(auth authenticator) {
+	if err := auth.closeSession(); err != nil {
+		log.Println(err)
+	}
+}
+
+// Defines specific environmental variables defined by authentication backend
+func defineSpecificEnvVariables(usr *sysuser, auth authenticator) {
+	for key, value := range auth.getEnvList() {
+		usr.setenv(key, value)
+	}
+}
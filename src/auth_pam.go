@@ -1,45 +1,89 @@
+//go:build !nopam
 // +build !nopam
 
 package src
 
+// #cgo LDFLAGS: -lpam
+// #include <security/pam_appl.h>
+import "C"
+
 import (
 	"bufio"
 	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/user"
 
 	"github.com/msteinert/pam"
 )
 
-const tagPam = ""
+const (
+	tagPam             = ""
+	defaultAuthBackend = constAuthPam
+)
+
+// pamAuth defines authentication of user and handling of his session by PAM.
+type pamAuth struct {
+	trans           *pam.Transaction
+	changingAuthTok bool
+	interrupted     bool
+}
+
+// Creates PAM authenticator.
+func newPamAuth() (authenticator, error) {
+	return &pamAuth{}, nil
+}
+
+// Starts PAM transaction and authenticates user through it.
+func (a *pamAuth) authenticate(conf *config) (string, error) {
+	trans, err := pam.StartFunc(conf.pamService, conf.defaultUser, a.conversation(conf))
+	if err != nil {
+		return "", err
+	}
+
+	username := ""
+	err = trans.Authenticate(pam.Silent)
+	if err != nil {
+		bkpErr := errors.New(err.Error())
+		username, _ = trans.GetItem(pam.User)
+		if a.interrupted {
+			a.interrupted = false
+			return username, errInterrupted
+		}
+		return username, bkpErr
+	}
 
-var trans *pam.Transaction
+	a.trans = trans
+	username, err = trans.GetItem(pam.User)
+	return username, err
+}
 
-// Handle PAM authentication of user.
-// If user is successfully authorized, it returns sysuser.
-//
-// If autologin is enabled, it behaves as user has been authorized.
-func authUser(conf *config) *sysuser {
-	var err error
+// Starts PAM transaction of autologin PAM service for default user without authentication.
+func (a *pamAuth) autologin(conf *config) error {
+	trans, err := pam.StartFunc(conf.autologinPamService, conf.defaultUser, a.conversation(conf))
+	if err != nil {
+		return err
+	}
+	a.trans = trans
+	return nil
+}
 
-	trans, err = pam.StartFunc("emptty", conf.defaultUser, func(s pam.Style, msg string) (string, error) {
+// Gets conversation function, that handles messages of PAM.
+func (a *pamAuth) conversation(conf *config) func(pam.Style, string) (string, error) {
+	return func(s pam.Style, msg string) (string, error) {
 		switch s {
 		case pam.PromptEchoOff:
-			if conf.autologin {
-				break
+			if a.changingAuthTok {
+				fmt.Print(msg)
+				return a.readPassword(conf.passwordMask)
 			}
 			if conf.defaultUser != "" {
 				hostname, _ := os.Hostname()
 				fmt.Printf("%s login: %s\n", hostname, conf.defaultUser)
 			}
 			fmt.Print("Password: ")
-			return readPassword()
+			return a.readPassword(conf.passwordMask)
 		case pam.PromptEchoOn:
-			if conf.autologin {
-				break
-			}
 			hostname, _ := os.Hostname()
 			fmt.Printf("%s login: ", hostname)
 			input, err := bufio.NewReader(os.Stdin).ReadString('\n')
@@ -49,55 +93,84 @@ func authUser(conf *config) *sysuser {
 			return input[:len(input)-1], nil
 		case pam.ErrorMsg:
 			log.Print(msg)
+			if a.changingAuthTok {
+				fmt.Println(msg)
+			}
 			return "", nil
 		case pam.TextInfo:
 			fmt.Println(msg)
 			return "", nil
 		}
 		return "", errors.New("Unrecognized message style")
-	})
+	}
+}
 
-	err = trans.Authenticate(pam.Silent)
-	if err != nil {
-		bkpErr := errors.New(err.Error())
-		username, _ := trans.GetItem(pam.User)
-		addBtmpEntry(username, os.Getpid(), conf.strTTY())
-		handleErr(bkpErr)
+// Reads password and remembers its interruption, because PAM does not pass errors of conversation.
+func (a *pamAuth) readPassword(mask bool) (string, error) {
+	password, err := readPassword(mask)
+	if err == errInterrupted {
+		a.interrupted = true
 	}
-	log.Print("Authenticate OK")
+	return password, err
+}
 
-	err = trans.AcctMgmt(pam.Silent)
-	handleErr(err)
+// Checks account of authenticated user and opens PAM session.
+// If password of user has expired, user is asked to change it first.
+func (a *pamAuth) openSession(conf *config) error {
+	if err := a.trans.AcctMgmt(pam.Silent); err != nil {
+		if !isNewAuthTokRequired(err) {
+			return err
+		}
+		if err := a.changeAuthTok(); err != nil {
+			return err
+		}
+	}
+	if err := a.trans.SetItem(pam.Tty, "tty"+conf.strTTY()); err != nil {
+		return err
+	}
+	return a.trans.OpenSession(pam.Silent)
+}
 
-	err = trans.SetItem(pam.Tty, "tty"+conf.strTTY())
-	handleErr(err)
+// Closes PAM session, if any is opened.
+func (a *pamAuth) closeSession() error {
+	if a.trans == nil {
+		return nil
+	}
+	err := a.trans.CloseSession(pam.Silent)
+	a.trans = nil
+	return err
+}
 
-	err = trans.OpenSession(pam.Silent)
-	handleErr(err)
+// Gets environmental variables defined by PAM.
+func (a *pamAuth) getEnvList() map[string]string {
+	if a.trans == nil {
+		return nil
+	}
+	envs, _ := a.trans.GetEnvList()
+	return envs
+}
 
-	pamUsr, _ := trans.GetItem(pam.User)
-	usr, _ := user.Lookup(pamUsr)
+// Lets user change expired password through PAM conversation.
+func (a *pamAuth) changeAuthTok() error {
+	log.Print("Password has expired")
+	fmt.Println("You are required to change your password immediately.")
 
-	return getSysuser(usr)
-}
+	a.changingAuthTok = true
+	defer func() { a.changingAuthTok = false }()
 
-// Handles close of PAM authentication
-func closeAuth() {
-	if trans != nil {
-		err := trans.CloseSession(pam.Silent)
-		trans = nil
-		if err != nil {
-			log.Println(err)
+	if err := a.trans.ChangeAuthTok(pam.ChangeExpiredAuthtok); err != nil {
+		if a.interrupted {
+			a.interrupted = false
+			return errInterrupted
 		}
+		return errors.New(err.Error())
 	}
+	log.Print("Password changed")
+	return nil
 }
 
-// Defines specific environmental variables defined by PAM
-func defineSpecificEnvVariables(usr *sysuser) {
-	if trans != nil {
-		envs, _ := trans.GetEnvList()
-		for key, value := range envs {
-			usr.setenv(key, value)
-		}
-	}
+// Checks, if PAM result means, that authentication token has expired and new one is required.
+// Transaction does not expose its status, so its message is compared.
+func isNewAuthTokRequired(err error) bool {
+	return err.Error() == C.GoString(C.pam_strerror(nil, C.PAM_NEW_AUTHTOK_REQD))
 }
@@ -0,0 +1,57 @@
+package src
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// shadowAuth defines authentication of user by password without PAM.
+type shadowAuth struct {
+}
+
+// Prompts for username and password and tries to authorize user.
+func (a *shadowAuth) authenticate(conf *config) (string, error) {
+	hostname, _ := os.Hostname()
+	var username string
+	if conf.defaultUser != "" {
+		fmt.Printf("%s login: %s\n", hostname, conf.defaultUser)
+		username = conf.defaultUser
+	} else {
+		fmt.Printf("%s login: ", hostname)
+		input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		handleErr(err)
+		username = input[:len(input)-1]
+	}
+	fmt.Print("Password: ")
+	password, err := readPassword(conf.passwordMask)
+	if err != nil {
+		return username, err
+	}
+
+	if !authPassword(username, password) {
+		return username, errors.New("Authentication failure")
+	}
+	return username, nil
+}
+
+// Nothing has to be prepared for autologin without PAM.
+func (a *shadowAuth) autologin(conf *config) error {
+	return nil
+}
+
+// Session is not handled without PAM.
+func (a *shadowAuth) openSession(conf *config) error {
+	return nil
+}
+
+// Session is not handled without PAM.
+func (a *shadowAuth) closeSession() error {
+	return nil
+}
+
+// No specific environmental variables are defined without PAM.
+func (a *shadowAuth) getEnvList() map[string]string {
+	return nil
+}
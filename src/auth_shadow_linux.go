@@ -1,5 +1,3 @@
-// +build nopam
-
 package src
 
 // #include <crypt.h>
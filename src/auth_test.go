@@ -0,0 +1,198 @@
+package src
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"strings"
+	"testing"
+)
+
+// fakeAuth defines authenticator, that returns scripted results.
+type fakeAuth struct {
+	attempts []fakeAuthAttempt
+	env      map[string]string
+	count    int
+	autolog  bool
+	opened   bool
+	closed   bool
+}
+
+// fakeAuthAttempt defines result of single authentication.
+type fakeAuthAttempt struct {
+	username string
+	err      error
+}
+
+func (a *fakeAuth) authenticate(conf *config) (string, error) {
+	attempt := a.attempts[a.count]
+	a.count++
+	return attempt.username, attempt.err
+}
+
+func (a *fakeAuth) autologin(conf *config) error {
+	a.autolog = true
+	return nil
+}
+
+func (a *fakeAuth) openSession(conf *config) error {
+	a.opened = true
+	return nil
+}
+
+func (a *fakeAuth) closeSession() error {
+	if !a.opened {
+		return errors.New("session is not opened")
+	}
+	a.closed = true
+	return nil
+}
+
+func (a *fakeAuth) getEnvList() map[string]string {
+	return a.env
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	auth, err := newAuthenticator(&config{authBackend: constAuthShadow})
+	if _, ok := auth.(*shadowAuth); !ok || err != nil {
+		t.Errorf("TestNewAuthenticator: shadow authenticator was expected, got %v, %v", auth, err)
+	}
+
+	if _, err := newAuthenticator(&config{authBackend: "unknown"}); err == nil {
+		t.Error("TestNewAuthenticator: unknown backend should not be created")
+	}
+}
+
+func TestAuthUser(t *testing.T) {
+	current, _ := user.Current()
+	auth := &fakeAuth{
+		attempts: []fakeAuthAttempt{{username: current.Username}},
+		env:      map[string]string{"XDG_SESSION_ID": "7"},
+	}
+	conf := &config{tty: 7}
+
+	usr := authUser(conf, auth)
+	if usr.username != current.Username || !auth.opened {
+		t.Errorf("TestAuthUser: unexpected user '%s' or session was not opened", usr.username)
+	}
+
+	defineSpecificEnvVariables(usr, auth)
+	if usr.getenv("XDG_SESSION_ID") != "7" {
+		t.Error("TestAuthUser: environmental variables of backend were not defined")
+	}
+
+	closeAuth(auth)
+	if !auth.closed {
+		t.Error("TestAuthUser: session was not closed")
+	}
+}
+
+func TestAuthUserFailedAttempts(t *testing.T) {
+	current, _ := user.Current()
+	conf := &config{tty: 7, maxLoginAttempts: 3}
+	failure := fakeAuthAttempt{username: current.Username, err: errors.New("Authentication failure")}
+
+	auth := &fakeAuth{attempts: []fakeAuthAttempt{failure, {username: current.Username}}}
+	readOutput(func() {
+		authUser(conf, auth)
+	})
+	if failedLoginAttempts != 0 {
+		t.Errorf("TestAuthUserFailedAttempts: failed attempts should be reset after success, got %d", failedLoginAttempts)
+	}
+
+	// attempts failed in previous iteration of login loop are still counted
+	failedLoginAttempts = 2
+	loginRunning = true
+	defer func() {
+		failedLoginAttempts = 0
+		loginRunning = false
+	}()
+
+	auth = &fakeAuth{attempts: []fakeAuthAttempt{failure, {username: current.Username}}}
+	var aborted interface{}
+	readOutput(func() {
+		defer func() {
+			aborted = recover()
+		}()
+		authUser(conf, auth)
+	})
+	if _, ok := aborted.(*abortedLogin); !ok || auth.count != 1 {
+		t.Error("TestAuthUserFailedAttempts: login should be aborted after MAX_LOGIN_ATTEMPTS across iterations")
+	}
+}
+
+func TestAuthUserAutologin(t *testing.T) {
+	current, _ := user.Current()
+	group, _ := user.LookupGroupId(current.Gid)
+	auth := &fakeAuth{}
+	conf := &config{tty: 7, autologin: true, defaultUser: current.Username, autologinGroup: group.Name}
+
+	usr := authUser(conf, auth)
+	if usr.username != current.Username || !auth.autolog || !auth.opened || auth.count != 0 {
+		t.Error("TestAuthUserAutologin: default user should be logged in without authentication")
+	}
+}
+
+func TestIsAutologinAllowed(t *testing.T) {
+	current, _ := user.Current()
+	group, _ := user.LookupGroupId(current.Gid)
+	dir, _ := ioutil.TempDir("", "emptty-autologin")
+	defer os.RemoveAll(dir)
+	marker := dir + "/run/autologin-tty7"
+
+	conf := &config{defaultUser: current.Username, autologinGroup: "emptty-missing-group"}
+	if isAutologinAllowed(conf, marker) {
+		t.Error("TestIsAutologinAllowed: user outside of autologin group should not be allowed")
+	}
+
+	conf.autologinGroup = group.Name
+	if !isAutologinAllowed(conf, marker) || !isAutologinAllowed(conf, marker) {
+		t.Error("TestIsAutologinAllowed: member of autologin group should be allowed repeatedly")
+	}
+
+	conf.autologinOnce = true
+	if !isAutologinAllowed(conf, marker) {
+		t.Error("TestIsAutologinAllowed: first autologin should be allowed")
+	}
+	if isAutologinAllowed(conf, marker) {
+		t.Error("TestIsAutologinAllowed: second autologin should not be allowed")
+	}
+}
+
+func TestShadowAuthSession(t *testing.T) {
+	auth := &shadowAuth{}
+	if auth.autologin(nil) != nil || auth.getEnvList() != nil || auth.openSession(nil) != nil || auth.closeSession() != nil {
+		t.Error("TestShadowAuthSession: shadow backend should not handle session")
+	}
+}
+
+func TestCountdownAutologin(t *testing.T) {
+	buf := new(bytes.Buffer)
+	reads := 0
+	if !countdownAutologin(buf, func(b []byte) (int, error) {
+		reads++
+		return 0, nil
+	}, "emptty", 3) {
+		t.Error("TestCountdownAutologin: autologin should continue without pressed key")
+	}
+	if reads != 3 || !strings.Contains(buf.String(), "in 1 s") {
+		t.Errorf("TestCountdownAutologin: unexpected countdown '%s'", buf.String())
+	}
+
+	buf.Reset()
+	reads = 0
+	if countdownAutologin(buf, func(b []byte) (int, error) {
+		reads++
+		if reads == 2 {
+			return 1, nil
+		}
+		return 0, nil
+	}, "emptty", 5) {
+		t.Error("TestCountdownAutologin: pressed key should cancel autologin")
+	}
+	if strings.Contains(buf.String(), "in 3 s") {
+		t.Errorf("TestCountdownAutologin: countdown should stop after pressed key '%s'", buf.String())
+	}
+}
@@ -3,30 +3,52 @@ package src
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 const (
-	confTTYnumber          = "TTY_NUMBER"
-	confSwitchTTY          = "SWITCH_TTY"
-	confPrintIssue         = "PRINT_ISSUE"
-	confDefaultUser        = "DEFAULT_USER"
-	confAutologin          = "AUTOLOGIN"
-	confAutologinSession   = "AUTOLOGIN_SESSION"
-	confLang               = "LANG"
-	confDbusLaunch         = "DBUS_LAUNCH"
-	confXinitrcLaunch      = "XINITRC_LAUNCH"
-	confVerticalSelection  = "VERTICAL_SELECTION"
-	confLogging            = "LOGGING"
-	confXorgArgs           = "XORG_ARGS"
-	confLoggingFile        = "LOGGING_FILE"
-	confDynamicMotd        = "DYNAMIC_MOTD"
-	confFgColor            = "FG_COLOR"
-	confBgColor            = "BG_COLOR"
-	confDisplayStartScript = "DISPLAY_START_SCRIPT"
-	confDisplayStopScript  = "DISPLAY_STOP_SCRIPT"
+	confTTYnumber           = "TTY_NUMBER"
+	confSwitchTTY           = "SWITCH_TTY"
+	confPrintIssue          = "PRINT_ISSUE"
+	confDefaultUser         = "DEFAULT_USER"
+	confAutologin           = "AUTOLOGIN"
+	confAutologinSession    = "AUTOLOGIN_SESSION"
+	confLang                = "LANG"
+	confDbusLaunch          = "DBUS_LAUNCH"
+	confXinitrcLaunch       = "XINITRC_LAUNCH"
+	confVerticalSelection   = "VERTICAL_SELECTION"
+	confLogging             = "LOGGING"
+	confXorgArgs            = "XORG_ARGS"
+	confLoggingFile         = "LOGGING_FILE"
+	confDynamicMotd         = "DYNAMIC_MOTD"
+	confFgColor             = "FG_COLOR"
+	confBgColor             = "BG_COLOR"
+	confDisplayStartScript  = "DISPLAY_START_SCRIPT"
+	confDisplayStopScript   = "DISPLAY_STOP_SCRIPT"
+	confMaxLoginAttempts    = "MAX_LOGIN_ATTEMPTS"
+	confSessionsPath        = "SESSIONS_PATH"
+	confDaemonLoop          = "DAEMON_LOOP"
+	confXorgStartTimeout    = "XORG_START_TIMEOUT"
+	confRootlessXorg        = "ROOTLESS_XORG"
+	confXorgLog             = "XORG_LOG"
+	confSessionLog          = "SESSION_LOG"
+	confDeniedEnvVars       = "DENIED_ENV_VARS"
+	confLogindSession       = "LOGIND_SESSION"
+	confDisallocateTTY      = "DISALLOCATE_TTY"
+	confPasswordMask        = "PASSWORD_MASK"
+	confAuthBackend         = "AUTH_BACKEND"
+	confPamService          = "PAM_SERVICE"
+	confAutologinPamService = "AUTOLOGIN_PAM_SERVICE"
+	confAutologinGroup      = "AUTOLOGIN_GROUP"
+	confAutologinOnce       = "AUTOLOGIN_ONCE"
+	confAutologinDelay      = "AUTOLOGIN_DELAY"
 
 	pathConfigFile = "/etc/emptty/conf"
 
+	defaultDeniedEnvVars = "PATH LD_PRELOAD LD_LIBRARY_PATH LD_AUDIT"
+
+	constTTYAuto = "auto"
+
 	constLogDefault   = "default"
 	constLogAppending = "appending"
 	constLogDisabled  = "disabled"
@@ -48,48 +70,84 @@ const (
 
 // config defines structure of application configuration.
 type config struct {
-	daemonMode         bool
-	defaultUser        string
-	autologin          bool
-	autologinSession   string
-	tty                int
-	switchTTY          bool
-	printIssue         bool
-	lang               string
-	dbusLaunch         bool
-	xinitrcLaunch      bool
-	verticalSelection  bool
-	logging            enLogging
-	xorgArgs           string
-	loggingFile        string
-	dynamicMotd        bool
-	fgColor            string
-	bgColor            string
-	displayStartScript string
-	displayStopScript  string
+	daemonMode          bool
+	defaultUser         string
+	autologin           bool
+	autologinSession    string
+	tty                 int
+	ttyAuto             bool
+	switchTTY           bool
+	printIssue          bool
+	lang                string
+	langDefined         bool
+	dbusLaunch          bool
+	xinitrcLaunch       bool
+	verticalSelection   bool
+	logging             enLogging
+	xorgArgs            string
+	loggingFile         string
+	dynamicMotd         bool
+	fgColor             string
+	bgColor             string
+	displayStartScript  string
+	displayStopScript   string
+	maxLoginAttempts    int
+	sessionsPath        string
+	daemonLoop          bool
+	xorgStartTimeout    int
+	rootlessXorg        bool
+	xorgLog             bool
+	sessionLog          enLogging
+	deniedEnvVars       []string
+	logindSession       bool
+	disallocateTTY      bool
+	passwordMask        bool
+	authBackend         string
+	pamService          string
+	autologinPamService string
+	autologinGroup      string
+	autologinOnce       bool
+	autologinDelay      int
 }
 
 // LoadConfig handles loading of application configuration.
 func loadConfig(path string) *config {
 	c := config{
-		daemonMode:         false,
-		tty:                0,
-		switchTTY:          true,
-		printIssue:         true,
-		defaultUser:        "",
-		autologin:          false,
-		autologinSession:   "",
-		dbusLaunch:         true,
-		xinitrcLaunch:      false,
-		verticalSelection:  false,
-		logging:            Default,
-		xorgArgs:           "",
-		loggingFile:        "",
-		dynamicMotd:        false,
-		fgColor:            "",
-		bgColor:            "",
-		displayStartScript: "",
-		displayStopScript:  "",
+		daemonMode:          false,
+		tty:                 0,
+		switchTTY:           true,
+		printIssue:          true,
+		defaultUser:         "",
+		autologin:           false,
+		autologinSession:    "",
+		dbusLaunch:          true,
+		xinitrcLaunch:       false,
+		verticalSelection:   false,
+		logging:             Default,
+		xorgArgs:            "",
+		loggingFile:         "",
+		dynamicMotd:         false,
+		fgColor:             "",
+		bgColor:             "",
+		displayStartScript:  "",
+		displayStopScript:   "",
+		maxLoginAttempts:    3,
+		sessionsPath:        "",
+		daemonLoop:          false,
+		xorgStartTimeout:    10,
+		rootlessXorg:        false,
+		xorgLog:             false,
+		sessionLog:          Disabled,
+		deniedEnvVars:       strings.Fields(defaultDeniedEnvVars),
+		logindSession:       false,
+		disallocateTTY:      false,
+		passwordMask:        false,
+		authBackend:         defaultAuthBackend,
+		pamService:          "emptty",
+		autologinPamService: "emptty-autologin",
+		autologinGroup:      "nopasswdlogin",
+		autologinOnce:       false,
+		autologinDelay:      0,
 	}
 
 	defaultLang := os.Getenv(envLang)
@@ -104,6 +162,7 @@ func loadConfig(path string) *config {
 			switch key {
 			case confTTYnumber:
 				c.tty = parseTTY(value, "0")
+				c.ttyAuto = isTTYAuto(value)
 			case confSwitchTTY:
 				c.switchTTY = parseBool(value, "true")
 			case confPrintIssue:
@@ -116,6 +175,7 @@ func loadConfig(path string) *config {
 				c.autologinSession = sanitizeValue(value, "")
 			case confLang:
 				c.lang = sanitizeValue(value, "en_US.UTF-8")
+				c.langDefined = true
 			case confDbusLaunch:
 				c.dbusLaunch = parseBool(value, "true")
 			case confXinitrcLaunch:
@@ -138,6 +198,40 @@ func loadConfig(path string) *config {
 				c.displayStartScript = sanitizeValue(value, "")
 			case confDisplayStopScript:
 				c.displayStopScript = sanitizeValue(value, "")
+			case confMaxLoginAttempts:
+				c.maxLoginAttempts = parseInt(value, "3")
+			case confSessionsPath:
+				c.sessionsPath = sanitizeValue(value, "")
+			case confDaemonLoop:
+				c.daemonLoop = parseBool(value, "false")
+			case confXorgStartTimeout:
+				c.xorgStartTimeout = parseInt(value, "10")
+			case confRootlessXorg:
+				c.rootlessXorg = parseBool(value, "false")
+			case confXorgLog:
+				c.xorgLog = parseBool(value, "false")
+			case confSessionLog:
+				c.sessionLog = parseLogging(value, constLogDisabled)
+			case confDeniedEnvVars:
+				c.deniedEnvVars = strings.Fields(sanitizeValue(value, defaultDeniedEnvVars))
+			case confLogindSession:
+				c.logindSession = parseBool(value, "false")
+			case confDisallocateTTY:
+				c.disallocateTTY = parseBool(value, "false")
+			case confPasswordMask:
+				c.passwordMask = parseBool(value, "false")
+			case confAuthBackend:
+				c.authBackend = sanitizeValue(value, defaultAuthBackend)
+			case confPamService:
+				c.pamService = sanitizeValue(value, "emptty")
+			case confAutologinPamService:
+				c.autologinPamService = sanitizeValue(value, "emptty-autologin")
+			case confAutologinGroup:
+				c.autologinGroup = sanitizeValue(value, "nopasswdlogin")
+			case confAutologinOnce:
+				c.autologinOnce = parseBool(value, "false")
+			case confAutologinDelay:
+				c.autologinDelay = parseInt(value, "0")
 			}
 		})
 		handleErr(err)
@@ -155,6 +249,11 @@ func parseTTY(tty string, defaultValue string) int {
 	return int(val)
 }
 
+// Checks, if TTY should be allocated automatically.
+func isTTYAuto(tty string) bool {
+	return sanitizeValue(tty, "") == constTTYAuto
+}
+
 // Parse logging option
 func parseLogging(strLogging string, defaultValue string) enLogging {
 	val := sanitizeValue(strLogging, defaultValue)
@@ -1,6 +1,9 @@
 package src
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestLoadConfig(t *testing.T) {
 	conf := loadConfig(getTestingPath("conf"))
@@ -29,7 +32,7 @@ func TestLoadConfig(t *testing.T) {
 		t.Error("TestLoadConfig: AUTOLOGIN_SESSION value is not correct")
 	}
 
-	if conf.lang != "en_US.UTF-8" {
+	if conf.lang != "en_US.UTF-8" || !conf.langDefined {
 		t.Error("TestLoadConfig: LANG value is not correct")
 	}
 
@@ -76,6 +79,74 @@ func TestLoadConfig(t *testing.T) {
 	if conf.displayStopScript != "/usr/bin/none" {
 		t.Error("TestLoadConfig: DISPLAY_STOP_SCRIPT value is not correct")
 	}
+
+	if conf.maxLoginAttempts != 5 {
+		t.Error("TestLoadConfig: MAX_LOGIN_ATTEMPTS value is not correct")
+	}
+
+	if conf.sessionsPath != "/opt/sessions" {
+		t.Error("TestLoadConfig: SESSIONS_PATH value is not correct")
+	}
+
+	if !conf.daemonLoop {
+		t.Error("TestLoadConfig: DAEMON_LOOP value is not correct")
+	}
+
+	if conf.xorgStartTimeout != 20 {
+		t.Error("TestLoadConfig: XORG_START_TIMEOUT value is not correct")
+	}
+
+	if !conf.rootlessXorg {
+		t.Error("TestLoadConfig: ROOTLESS_XORG value is not correct")
+	}
+
+	if !conf.xorgLog {
+		t.Error("TestLoadConfig: XORG_LOG value is not correct")
+	}
+
+	if conf.sessionLog != Appending {
+		t.Error("TestLoadConfig: SESSION_LOG value is not correct")
+	}
+
+	if strings.Join(conf.deniedEnvVars, ";") != "PATH;LD_PRELOAD" {
+		t.Error("TestLoadConfig: DENIED_ENV_VARS value is not correct")
+	}
+
+	if !conf.logindSession {
+		t.Error("TestLoadConfig: LOGIND_SESSION value is not correct")
+	}
+
+	if !conf.disallocateTTY {
+		t.Error("TestLoadConfig: DISALLOCATE_TTY value is not correct")
+	}
+
+	if !conf.passwordMask {
+		t.Error("TestLoadConfig: PASSWORD_MASK value is not correct")
+	}
+
+	if conf.authBackend != "shadow" {
+		t.Error("TestLoadConfig: AUTH_BACKEND value is not correct")
+	}
+
+	if conf.pamService != "emptty-kiosk" {
+		t.Error("TestLoadConfig: PAM_SERVICE value is not correct")
+	}
+
+	if conf.autologinPamService != "emptty-kiosk-autologin" {
+		t.Error("TestLoadConfig: AUTOLOGIN_PAM_SERVICE value is not correct")
+	}
+
+	if conf.autologinGroup != "autologin" {
+		t.Error("TestLoadConfig: AUTOLOGIN_GROUP value is not correct")
+	}
+
+	if !conf.autologinOnce {
+		t.Error("TestLoadConfig: AUTOLOGIN_ONCE value is not correct")
+	}
+
+	if conf.autologinDelay != 5 {
+		t.Error("TestLoadConfig: AUTOLOGIN_DELAY value is not correct")
+	}
 }
 
 func TestParseTTY(t *testing.T) {
@@ -97,6 +168,16 @@ func TestParseTTY(t *testing.T) {
 	}
 }
 
+func TestIsTTYAuto(t *testing.T) {
+	if !isTTYAuto(" auto") || parseTTY("auto", "0") != 0 {
+		t.Error("TestIsTTYAuto: auto value was not recognized")
+	}
+
+	if isTTYAuto("7") || isTTYAuto("") {
+		t.Error("TestIsTTYAuto: only auto value should allocate TTY automatically")
+	}
+}
+
 func TestParseLogging(t *testing.T) {
 	var logging enLogging
 
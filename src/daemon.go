@@ -6,7 +6,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
+	"os/user"
 	"sort"
 	"strconv"
 	"strings"
@@ -15,6 +15,7 @@ import (
 const (
 	strCleanScreen = "\x1b[H\x1b[2J"
 	pathIssue      = "/etc/issue"
+	ttyGroup       = "tty"
 )
 
 // IssueVariable defines list of all escape sequences found in issue file
@@ -26,6 +27,23 @@ type issueVariable struct {
 
 // Starts emptty as daemon spawning emptty on defined TTY.
 func startDaemon(conf *config) *os.File {
+	if conf.ttyAuto {
+		tty, err := getFreeTTY()
+		if err != nil {
+			log.Fatal(err)
+		}
+		conf.tty = tty
+	}
+
+	// TTY could be left owned by previous user, if emptty exited during rootless Xorg session
+	if err := resetTTYOwnership("/dev/tty" + conf.strTTY()); err != nil {
+		log.Print(err)
+	}
+	// Processes left by previous user could still have the TTY opened
+	if err := hangupTTY("/dev/tty" + conf.strTTY()); err != nil {
+		log.Print(err)
+	}
+
 	fTTY, err := os.OpenFile("/dev/tty"+conf.strTTY(), os.O_RDWR, 0700)
 	if err != nil {
 		log.Fatal(err)
@@ -51,6 +69,21 @@ func startDaemon(conf *config) *os.File {
 	return fTTY
 }
 
+// Resets ownership of TTY to root and tty group, so no other user could access it.
+func resetTTYOwnership(path string) error {
+	gid := 0
+	mode := os.FileMode(0600)
+	if group, err := user.LookupGroup(ttyGroup); err == nil {
+		gid, _ = strconv.Atoi(group.Gid)
+		mode = 0620
+	}
+
+	if err := os.Chown(path, 0, gid); err != nil {
+		return err
+	}
+	return os.Chmod(path, mode)
+}
+
 // Stops daemon mode and closes opened TTY.
 func stopDaemon(conf *config, fTTY *os.File) {
 	resetColors()
@@ -73,8 +106,9 @@ func clearScreen(w io.Writer) {
 // Perform switch to defined TTY, if switchTTY is true and tty is greater than 0.
 func switchTTY(conf *config) bool {
 	if conf.switchTTY && conf.tty > 0 {
-		ttyCmd := exec.Command("/usr/bin/chvt", conf.strTTY())
-		ttyCmd.Run()
+		if err := activateTTY(conf.tty); err != nil {
+			log.Print(err)
+		}
 		return true
 	}
 	return false
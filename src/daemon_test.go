@@ -2,6 +2,9 @@ package src
 
 import (
 	"bytes"
+	"io/ioutil"
+	"os"
+	"syscall"
 	"testing"
 )
 
@@ -62,3 +65,33 @@ func TestSwitchTTY(t *testing.T) {
 		t.Error("TestSwitchTTY: attempt to switch tty with negative number")
 	}
 }
+
+func TestResetTTYOwnership(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("TestResetTTYOwnership: changing ownership requires root")
+	}
+
+	f, err := ioutil.TempFile("", "emptty-tty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	os.Chown(f.Name(), 3000, 2000)
+	if err := resetTTYOwnership(f.Name()); err != nil {
+		t.Fatalf("TestResetTTYOwnership: %v", err)
+	}
+
+	info, _ := os.Stat(f.Name())
+	if stat := info.Sys().(*syscall.Stat_t); stat.Uid != 0 || stat.Gid == 2000 {
+		t.Errorf("TestResetTTYOwnership: TTY ownership was not reset: %d:%d", stat.Uid, stat.Gid)
+	}
+	if info.Mode().Perm()&0077&^0020 != 0 {
+		t.Errorf("TestResetTTYOwnership: TTY is accessible by others: %v", info.Mode().Perm())
+	}
+
+	if err := resetTTYOwnership(f.Name() + "-missing"); err == nil {
+		t.Error("TestResetTTYOwnership: missing TTY should return error")
+	}
+}
@@ -2,11 +2,12 @@ package src
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
-	"os/user"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -19,13 +20,25 @@ const (
 	desktopExec        = "EXEC"
 	desktopName        = "NAME"
 	desktopEnvironment = "ENVIRONMENT"
+	desktopTryExec     = "TRYEXEC"
+	desktopHidden      = "HIDDEN"
+	desktopNoDisplay   = "NODISPLAY"
+	desktopNamesKey    = "DESKTOPNAMES"
+	desktopEnvPrefix   = "ENV_"
+
+	desktopEntryGroup = "Desktop Entry"
 
 	constEnvXorg    = "xorg"
 	constEnvWayland = "wayland"
+	constEnvConsole = "console"
+	constEnvTty     = "tty"
+
+	constConsoleName = "Console"
 
 	pathLastSession       = "/.cache/emptty/last-session"
-	pathXorgSessions      = "/usr/share/xsessions/"
-	pathWaylandSessions   = "/usr/share/wayland-sessions/"
+	pathXorgSessions      = "/xsessions/"
+	pathWaylandSessions   = "/wayland-sessions/"
+	pathDefaultDataDirs   = "/usr/local/share/:/usr/share/"
 	pathCustomSessions    = "/etc/emptty/custom-sessions/"
 	pathUserCustomSession = "/.config/emptty-custom-sessions/"
 )
@@ -42,17 +55,26 @@ const (
 
 	// Custom represents user's custom desktops, only helper before real env is loaded
 	Custom
+
+	// Console represents user's login shell started directly on TTY
+	Console
 )
 
 // desktop defines structure for display environments and window managers.
 type desktop struct {
-	name      string
-	exec      string
-	env       enEnvironment
-	isUser    bool
-	path      string
-	selection bool
-	child     *desktop
+	name          string
+	localizedName string
+	exec          string
+	env           enEnvironment
+	isUser        bool
+	path          string
+	selection     bool
+	child         *desktop
+	tryExec       string
+	hidden        bool
+	noDisplay     bool
+	desktopNames  string
+	envVars       []string
 }
 
 // lastSession defines structure for last used session on user login.
@@ -63,11 +85,15 @@ type lastSession struct {
 
 // Allows to select desktop, which could be selected.
 func selectDesktop(usr *sysuser, conf *config) *desktop {
-	desktops := listAllDesktops(usr, pathXorgSessions, pathWaylandSessions)
-	if len(desktops) == 0 {
-		handleStrErr("Not found any installed desktop.")
+	var xorgPaths, waylandPaths []string
+	for _, dataDir := range getSessionsDataDirs(conf) {
+		xorgPaths = append(xorgPaths, dataDir+pathXorgSessions)
+		waylandPaths = append(waylandPaths, dataDir+pathWaylandSessions)
 	}
 
+	desktops := listAllDesktops(usr, xorgPaths, waylandPaths, conf.lang)
+	desktops = append(desktops, &desktop{name: constConsoleName, env: Console})
+
 	lastDesktop := getLastDesktop(usr, desktops)
 
 	if conf.autologin && conf.autologinSession != "" {
@@ -81,6 +107,29 @@ func selectDesktop(usr *sysuser, conf *config) *desktop {
 		}
 	}
 
+	id := -1
+	if isTerminal(os.Stdin.Fd()) {
+		var err error
+		id, err = selectDesktopFromMenu(desktops, lastDesktop, conf)
+		if err == errInterrupted {
+			handleInterruptErr(err)
+		} else if err != nil {
+			log.Print(err)
+		}
+	}
+	if id < 0 {
+		id = selectDesktopByNumber(desktops, lastDesktop, conf)
+	}
+
+	d := desktops[id]
+	if isLastDesktopForSave(usr, desktops[lastDesktop], d) {
+		setUserLastSession(usr, d)
+	}
+	return d
+}
+
+// Lets user select desktop by typing its number, it is used when input is not a terminal.
+func selectDesktopByNumber(desktops []*desktop, lastDesktop int, conf *config) int {
 	for true {
 		fmt.Printf("\n")
 		for i, v := range desktops {
@@ -91,7 +140,7 @@ func selectDesktop(usr *sysuser, conf *config) *desktop {
 					fmt.Print(", ")
 				}
 			}
-			fmt.Printf("[%d] %s", i, v.name)
+			fmt.Printf("[%d] %s", i, v.getDisplayName())
 		}
 		fmt.Printf("\nSelect [%d]: ", lastDesktop)
 
@@ -106,40 +155,55 @@ func selectDesktop(usr *sysuser, conf *config) *desktop {
 			continue
 		}
 		if int(id) < len(desktops) {
-			d := desktops[id]
-			if isLastDesktopForSave(usr, desktops[lastDesktop], d) {
-				setUserLastSession(usr, d)
-			}
-			return d
+			return int(id)
 		}
 	}
-	return nil
+	return lastDesktop
+}
+
+// Gets data directories, where Xorg and Wayland sessions are searched.
+// Directories defined by SESSIONS_PATH are followed by directories from XDG_DATA_DIRS.
+func getSessionsDataDirs(conf *config) []string {
+	var result []string
+
+	xdgDataDirs := os.Getenv(envXdgDataDirs)
+	if xdgDataDirs == "" {
+		xdgDataDirs = pathDefaultDataDirs
+	}
+
+	for _, dataDir := range strings.Split(conf.sessionsPath+":"+xdgDataDirs, ":") {
+		dataDir = strings.TrimRight(strings.TrimSpace(dataDir), "/")
+		if dataDir != "" && !contains(result, dataDir) {
+			result = append(result, dataDir)
+		}
+	}
+	return result
 }
 
 // List all installed desktops and return their exec commands.
-func listAllDesktops(usr *sysuser, pathXorgDesktops string, pathWaylandDesktops string) []*desktop {
+func listAllDesktops(usr *sysuser, pathsXorgDesktops []string, pathsWaylandDesktops []string, lang string) []*desktop {
 	var result []*desktop
 
 	// load Xorg desktops
-	xorgDesktops := listDesktops(pathXorgDesktops, Xorg)
+	xorgDesktops := listDesktops(pathsXorgDesktops, Xorg, lang)
 	if xorgDesktops != nil && len(xorgDesktops) > 0 {
 		result = append(result, xorgDesktops...)
 	}
 
 	// load Wayland desktops
-	waylandDesktops := listDesktops(pathWaylandDesktops, Wayland)
+	waylandDesktops := listDesktops(pathsWaylandDesktops, Wayland, lang)
 	if waylandDesktops != nil && len(waylandDesktops) > 0 {
 		result = append(result, waylandDesktops...)
 	}
 
 	// load custom desktops
-	customDesktops := listDesktops(pathCustomSessions, Custom)
+	customDesktops := listDesktops([]string{pathCustomSessions}, Custom, lang)
 	if customDesktops != nil && len(customDesktops) > 0 {
 		result = append(result, customDesktops...)
 	}
 
 	// load custom user desktops
-	customUserDesktops := listDesktops(usr.homedir+pathUserCustomSession, Custom)
+	customUserDesktops := listDesktops([]string{usr.homedir + pathUserCustomSession}, Custom, lang)
 	if customUserDesktops != nil && len(customUserDesktops) > 0 {
 		result = append(result, customUserDesktops...)
 	}
@@ -147,15 +211,28 @@ func listAllDesktops(usr *sysuser, pathXorgDesktops string, pathWaylandDesktops
 	return result
 }
 
-// List desktops, that could be found on defined path.
-func listDesktops(path string, env enEnvironment) []*desktop {
+// List desktops, that could be found on defined paths. Hidden desktops and desktops with missing TryExec are skipped.
+// If desktop file with the same desktop file ID is found in more paths, only the first one is used.
+func listDesktops(paths []string, env enEnvironment, lang string) []*desktop {
 	var result []*desktop
+	knownIds := make(map[string]bool)
 
-	if fileExists(path) {
+	for _, path := range paths {
+		if !fileExists(path) {
+			continue
+		}
 		err := filepath.Walk(path, func(filePath string, fileInfo os.FileInfo, err error) error {
-			if !fileInfo.IsDir() && strings.HasSuffix(filePath, ".desktop") {
-				d := getDesktop(filePath, env)
-				result = append(result, d)
+			if err == nil && !fileInfo.IsDir() && strings.HasSuffix(filePath, ".desktop") {
+				id := getDesktopFileId(path, filePath)
+				if knownIds[id] {
+					return nil
+				}
+				knownIds[id] = true
+
+				d := getDesktop(filePath, env, lang)
+				if isDesktopAvailable(d) {
+					result = append(result, d)
+				}
 			}
 			return nil
 		})
@@ -165,26 +242,230 @@ func listDesktops(path string, env enEnvironment) []*desktop {
 	return result
 }
 
+// Gets desktop file ID, that is relative path of desktop file with "/" replaced by "-".
+func getDesktopFileId(path string, filePath string) string {
+	id, err := filepath.Rel(path, filePath)
+	if err != nil {
+		return filePath
+	}
+	return strings.ReplaceAll(id, "/", "-")
+}
+
 // Inits desktop object from .desktop file on defined path.
-func getDesktop(path string, env enEnvironment) *desktop {
+// Name is kept unlocalized, localized name is chosen according to lang, if the file contains matching translation.
+func getDesktop(path string, env enEnvironment, lang string) *desktop {
 	d := desktop{env: env, isUser: false, path: path}
 	if env == Custom {
 		d.env = Xorg
 	}
 
-	readProperties(path, func(key string, value string) {
+	namePriority := -1
+	readDesktopEntry(path, func(key string, locale string, value string) {
 		switch key {
 		case desktopName:
-			d.name = value
+			if locale == "" {
+				d.name = value
+			}
+			priority := getLocalePriority(locale, lang)
+			if priority > namePriority {
+				d.localizedName = value
+				namePriority = priority
+			}
 		case desktopExec:
-			d.exec = value
+			d.exec = stripExecFieldCodes(value)
 		case desktopEnvironment:
 			d.env = parseEnv(value, constEnvXorg)
+		case desktopTryExec:
+			d.tryExec = value
+		case desktopHidden:
+			d.hidden = parseBool(value, "false")
+		case desktopNoDisplay:
+			d.noDisplay = parseBool(value, "false")
+		case desktopNamesKey:
+			d.desktopNames = parseDesktopNames(value)
+		default:
+			d.envVars = appendDesktopEnvVar(d.envVars, key, value)
 		}
 	})
 	return &d
 }
 
+// Parses semicolon-separated DesktopNames into colon-separated value used by XDG_CURRENT_DESKTOP.
+func parseDesktopNames(value string) string {
+	var names []string
+	for _, name := range strings.Split(value, ";") {
+		if strings.TrimSpace(name) != "" {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+	return strings.Join(names, ":")
+}
+
+// Gets name of desktop shown to user, it is localized if possible.
+func (d *desktop) getDisplayName() string {
+	if d.localizedName != "" {
+		return d.localizedName
+	}
+	return d.name
+}
+
+// Checks, if desktop should be offered for selection.
+func isDesktopAvailable(d *desktop) bool {
+	if d.hidden || d.noDisplay {
+		return false
+	}
+	if d.tryExec == "" {
+		return true
+	}
+	if filepath.IsAbs(d.tryExec) {
+		return fileIsExecutable(d.tryExec)
+	}
+	_, err := exec.LookPath(d.tryExec)
+	return err == nil
+}
+
+// desktopEntryFunc defines method to be invoked during readDesktopEntry method for each key of Desktop Entry.
+type desktopEntryFunc func(key string, locale string, value string)
+
+// readDesktopEntry reads defined filePath as freedesktop Desktop Entry file.
+// Only keys from "Desktop Entry" group are used, keys of other groups (e.g. Desktop Action) are skipped.
+// Keys defined before any group are also accepted to keep support of simple custom session files.
+func readDesktopEntry(filePath string, method desktopEntryFunc) error {
+	file, err := os.Open(filePath)
+	defer file.Close()
+	if err != nil {
+		return errors.New("Could not open file " + filePath)
+	}
+
+	group := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = line[1 : len(line)-1]
+			continue
+		}
+		if (group != "" && group != desktopEntryGroup) || strings.Index(line, "=") < 0 {
+			continue
+		}
+
+		splitIndex := strings.Index(line, "=")
+		key := strings.TrimSpace(line[:splitIndex])
+		value := strings.TrimSpace(line[splitIndex+1:])
+
+		locale := ""
+		if strings.HasSuffix(key, "]") && strings.Index(key, "[") > 0 {
+			locale = key[strings.Index(key, "[")+1 : len(key)-1]
+			key = key[:strings.Index(key, "[")]
+		}
+		method(normalizePropertyKey(key), locale, unescapeDesktopValue(value))
+	}
+	return scanner.Err()
+}
+
+// Unescapes \s, \n, \t, \r and \\ sequences of Desktop Entry value.
+func unescapeDesktopValue(value string) string {
+	if strings.Index(value, "\\") < 0 {
+		return value
+	}
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 's':
+				sb.WriteByte(' ')
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '\\':
+				sb.WriteByte('\\')
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(value[i])
+			}
+		} else {
+			sb.WriteByte(value[i])
+		}
+	}
+	return sb.String()
+}
+
+// Removes field codes (e.g. %f or %U) from Exec value, "%%" is replaced by "%".
+func stripExecFieldCodes(value string) string {
+	if strings.Index(value, "%") < 0 {
+		return value
+	}
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '%' && i+1 < len(value) {
+			i++
+			if value[i] == '%' {
+				sb.WriteByte('%')
+			}
+		} else {
+			sb.WriteByte(value[i])
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// Gets priority of Desktop Entry locale for defined lang (e.g. "cs_CZ.UTF-8").
+// The best match is lang_COUNTRY@MODIFIER, then lang_COUNTRY, lang@MODIFIER and lang.
+// Value without locale has priority 0, not matching locale returns -1.
+func getLocalePriority(locale string, lang string) int {
+	if locale == "" {
+		return 0
+	}
+
+	language, country, modifier := parseLocale(lang)
+	if language == "" {
+		return -1
+	}
+
+	candidates := []string{language}
+	if modifier != "" {
+		candidates = append(candidates, language+"@"+modifier)
+	}
+	if country != "" {
+		candidates = append(candidates, language+"_"+country)
+		if modifier != "" {
+			candidates = append(candidates, language+"_"+country+"@"+modifier)
+		}
+	}
+
+	for i := len(candidates) - 1; i >= 0; i-- {
+		if candidates[i] == locale {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// Parses locale value into its language, country and modifier, encoding is skipped.
+func parseLocale(value string) (string, string, string) {
+	modifier := ""
+	if strings.Index(value, "@") >= 0 {
+		modifier = value[strings.Index(value, "@")+1:]
+		value = value[:strings.Index(value, "@")]
+	}
+	if strings.Index(value, ".") >= 0 {
+		value = value[:strings.Index(value, ".")]
+	}
+	country := ""
+	if strings.Index(value, "_") >= 0 {
+		country = value[strings.Index(value, "_")+1:]
+		value = value[:strings.Index(value, "_")]
+	}
+	return value, country, modifier
+}
+
 // Parses user-specified configuration from file and returns it as desktop structure.
 func loadUserDesktop(homeDir string) (*desktop, string) {
 	homeDirConf := homeDir + "/.emptty"
@@ -207,6 +488,17 @@ func loadUserDesktop(homeDir string) (*desktop, string) {
 					lang = value
 				case confSelection:
 					d.selection = parseBool(value, "false")
+				case desktopNamesKey:
+					d.desktopNames = parseDesktopNames(value)
+				default:
+					d.envVars = appendDesktopEnvVar(d.envVars, key, value)
+				}
+			})
+			handleErr(err)
+
+			err = readExportedVariables(confFile, func(key string, value string) {
+				if !strings.HasPrefix(key, desktopEnvPrefix) {
+					d.envVars = append(d.envVars, key+"="+value)
 				}
 			})
 			handleErr(err)
@@ -265,23 +557,26 @@ func getUserLastSession(usr *sysuser) *lastSession {
 
 // Sets Last session for declared sysuser and saves it into user's home directory.
 func setUserLastSession(usr *sysuser, d *desktop) {
-	currentUser, _ := user.Current()
-	previousUser := getSysuser(currentUser)
-
-	setFsUser(usr)
+	doAsUser(usr, func() {
+		path := usr.homedir + pathLastSession
+		data := fmt.Sprintf("%s;%s\n", d.exec, d.env.stringify())
+		err := mkDirsForFile(path, 0744)
+		if err != nil {
+			log.Print(err)
+		}
+		err = ioutil.WriteFile(path, []byte(data), 0600)
+		if err != nil {
+			log.Print(err)
+		}
+	})
+}
 
-	path := usr.homedir + pathLastSession
-	data := fmt.Sprintf("%s;%s\n", d.exec, d.env.stringify())
-	err := mkDirsForFile(path, 0744)
-	if err != nil {
-		log.Print(err)
+// Appends environmental variable, if key is prefixed with "ENV_".
+func appendDesktopEnvVar(envVars []string, key string, value string) []string {
+	if strings.HasPrefix(key, desktopEnvPrefix) && isValidEnvKey(key[len(desktopEnvPrefix):]) {
+		return append(envVars, key[len(desktopEnvPrefix):]+"="+value)
 	}
-	err = ioutil.WriteFile(path, []byte(data), 0600)
-	if err != nil {
-		log.Print(err)
-	}
-
-	setFsUser(previousUser)
+	return envVars
 }
 
 // Checks, if user last session file already exists.
@@ -296,6 +591,8 @@ func parseEnv(env string, defaultValue string) enEnvironment {
 		return Xorg
 	case constEnvWayland:
 		return Wayland
+	case constEnvConsole, constEnvTty:
+		return Console
 	}
 	return Xorg
 }
@@ -307,6 +604,8 @@ func (e enEnvironment) stringify() string {
 		return constEnvXorg
 	case Wayland:
 		return constEnvWayland
+	case Console:
+		return constEnvConsole
 	}
 	return constEnvXorg
 }
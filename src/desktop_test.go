@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"strings"
 	"testing"
 )
 
@@ -19,6 +20,10 @@ func TestStringifyEnv(t *testing.T) {
 	if Custom.stringify() != constEnvXorg {
 		t.Error("TestStringifyEnv: wrong value for Custom env")
 	}
+
+	if Console.stringify() != constEnvConsole {
+		t.Error("TestStringifyEnv: wrong value for Console env")
+	}
 }
 
 func TestParseEnv(t *testing.T) {
@@ -39,6 +44,16 @@ func TestParseEnv(t *testing.T) {
 		t.Error("TestParseEnv: wrong parsed value for wayland")
 	}
 
+	env = parseEnv("console", "xorg")
+	if env != Console {
+		t.Error("TestParseEnv: wrong parsed value for console")
+	}
+
+	env = parseEnv("tty", "xorg")
+	if env != Console {
+		t.Error("TestParseEnv: wrong parsed value for tty")
+	}
+
 	env = parseEnv("aaa", "bbb")
 	if env != Xorg {
 		t.Error("TestParseEnv: wrong fallback value")
@@ -71,6 +86,14 @@ func TestLoadUserDesktop(t *testing.T) {
 		t.Error("TestLoadUserDesktop: wrong isUser value")
 	}
 
+	if d.desktopNames != "window-manager" {
+		t.Error("TestLoadUserDesktop: wrong DESKTOPNAMES value")
+	}
+
+	if strings.Join(d.envVars, ";") != "MOZ_ENABLE_WAYLAND=1;http_proxy=http://proxy:3128;QT_QPA_PLATFORM=wayland;GREETING=hello ${USER}" {
+		t.Errorf("TestLoadUserDesktop: wrong environmental variables %v", d.envVars)
+	}
+
 	readOutput(func() {
 		d, _ = loadUserDesktop(getTestingPath("userHome3"))
 		if d != nil {
@@ -85,7 +108,7 @@ func TestLoadUserDesktop(t *testing.T) {
 }
 
 func TestGetDesktop(t *testing.T) {
-	d := getDesktop(getTestingPath("userHome/.config/emptty"), Custom)
+	d := getDesktop(getTestingPath("userHome/.config/emptty"), Custom, "")
 
 	if d.exec != "none" {
 		t.Error("TestLoadUserDesktop: wrong EXEC value")
@@ -108,6 +131,92 @@ func TestGetDesktop(t *testing.T) {
 	}
 }
 
+func TestGetDesktopEntry(t *testing.T) {
+	d := getDesktop(getTestingPath("desktop-entry.desktop"), Xorg, "en_US.UTF-8")
+
+	if d.name != "Desktop Entry" {
+		t.Errorf("TestGetDesktopEntry: wrong default Name value '%s'", d.name)
+	}
+
+	if d.exec != "/usr/bin/desktop-entry --file --urls 100%" {
+		t.Errorf("TestGetDesktopEntry: wrong Exec value '%s'", d.exec)
+	}
+
+	if d.desktopNames != "Testing:Entry" {
+		t.Errorf("TestGetDesktopEntry: wrong DesktopNames value '%s'", d.desktopNames)
+	}
+
+	if d.tryExec != "sh" || !isDesktopAvailable(d) {
+		t.Error("TestGetDesktopEntry: desktop with existing TryExec should be available")
+	}
+
+	if len(d.envVars) != 1 || d.envVars[0] != "GDK_BACKEND=x11" {
+		t.Errorf("TestGetDesktopEntry: wrong environmental variables %v", d.envVars)
+	}
+
+	d = getDesktop(getTestingPath("desktop-entry.desktop"), Xorg, "cs_CZ.UTF-8")
+	if d.name != "Desktop Entry" || d.getDisplayName() != "Pracovní prostředí CZ" {
+		t.Errorf("TestGetDesktopEntry: wrong localized Name value '%s'", d.getDisplayName())
+	}
+
+	d = getDesktop(getTestingPath("desktop-entry.desktop"), Xorg, "de_AT.UTF-8@euro")
+	if d.name != "Desktop Entry" || d.getDisplayName() != "Arbeitsumgebung" {
+		t.Errorf("TestGetDesktopEntry: wrong localized Name value '%s'", d.getDisplayName())
+	}
+}
+
+func TestParseDesktopNames(t *testing.T) {
+	if parseDesktopNames("") != "" {
+		t.Error("TestParseDesktopNames: empty value was expected")
+	}
+
+	if value := parseDesktopNames("GNOME-Classic; GNOME;"); value != "GNOME-Classic:GNOME" {
+		t.Errorf("TestParseDesktopNames: unexpected value '%s'", value)
+	}
+}
+
+func TestIsDesktopAvailable(t *testing.T) {
+	if !isDesktopAvailable(&desktop{}) {
+		t.Error("TestIsDesktopAvailable: desktop without TryExec should be available")
+	}
+
+	if isDesktopAvailable(&desktop{hidden: true}) || isDesktopAvailable(&desktop{noDisplay: true}) {
+		t.Error("TestIsDesktopAvailable: hidden desktop should not be available")
+	}
+
+	if isDesktopAvailable(&desktop{tryExec: "/dev/null/none"}) || isDesktopAvailable(&desktop{tryExec: "emptty-non-existing-command"}) {
+		t.Error("TestIsDesktopAvailable: desktop with missing TryExec should not be available")
+	}
+}
+
+func TestStripExecFieldCodes(t *testing.T) {
+	if stripExecFieldCodes("/usr/bin/none") != "/usr/bin/none" {
+		t.Error("TestStripExecFieldCodes: value without field codes should stay untouched")
+	}
+
+	if value := stripExecFieldCodes("/usr/bin/none %F --arg %u %%"); value != "/usr/bin/none --arg %" {
+		t.Errorf("TestStripExecFieldCodes: unexpected value '%s'", value)
+	}
+}
+
+func TestGetLocalePriority(t *testing.T) {
+	if getLocalePriority("", "cs_CZ.UTF-8") != 0 {
+		t.Error("TestGetLocalePriority: value without locale should have default priority")
+	}
+
+	if getLocalePriority("de", "cs_CZ.UTF-8") >= 0 {
+		t.Error("TestGetLocalePriority: different language should not match")
+	}
+
+	if getLocalePriority("cs", "cs_CZ.UTF-8") >= getLocalePriority("cs_CZ", "cs_CZ.UTF-8") {
+		t.Error("TestGetLocalePriority: language with country should have higher priority")
+	}
+
+	if getLocalePriority("sr_RS", "sr_RS.UTF-8@latin") >= getLocalePriority("sr_RS@latin", "sr_RS.UTF-8@latin") {
+		t.Error("TestGetLocalePriority: locale with modifier should have higher priority")
+	}
+}
+
 func TestGetUserLastSession(t *testing.T) {
 	usr := &sysuser{}
 	usr.homedir = getTestingPath("userHome2")
@@ -137,14 +246,14 @@ func TestGetLastDesktop(t *testing.T) {
 }
 
 func TestListDesktops(t *testing.T) {
-	desktops := listDesktops(getTestingPath("userHome"), Custom)
+	desktops := listDesktops([]string{getTestingPath("userHome")}, Custom, "")
 	if len(desktops) > 0 {
 		t.Error("TestListDesktops: no desktop was expected")
 	}
 
-	desktops = listDesktops(getTestingPath("desktops"), Custom)
-	if len(desktops) == 0 {
-		t.Error("TestListDesktops: desktops were expected")
+	desktops = listDesktops([]string{getTestingPath("desktops")}, Custom, "")
+	if len(desktops) != 2 {
+		t.Error("TestListDesktops: 2 desktops were expected, hidden and unavailable should be skipped")
 	}
 
 	for _, d := range desktops {
@@ -154,6 +263,43 @@ func TestListDesktops(t *testing.T) {
 	}
 }
 
+func TestListDesktopsShadowing(t *testing.T) {
+	desktops := listDesktops([]string{getTestingPath("sessions-data/xsessions"), getTestingPath("desktops")}, Xorg, "")
+
+	if len(desktops) != 1 {
+		t.Fatalf("TestListDesktopsShadowing: 1 desktop was expected, found %d", len(desktops))
+	}
+
+	if desktops[0].name != "Shadowing Desktop1" {
+		t.Error("TestListDesktopsShadowing: desktop from the first path should shadow others")
+	}
+}
+
+func TestGetSessionsDataDirs(t *testing.T) {
+	original := os.Getenv(envXdgDataDirs)
+	defer os.Setenv(envXdgDataDirs, original)
+
+	c := &config{}
+	os.Setenv(envXdgDataDirs, "")
+	dirs := getSessionsDataDirs(c)
+	if len(dirs) != 2 || dirs[0] != "/usr/local/share" || dirs[1] != "/usr/share" {
+		t.Errorf("TestGetSessionsDataDirs: unexpected default data dirs %v", dirs)
+	}
+
+	c.sessionsPath = "/opt/sessions:/usr/share/"
+	os.Setenv(envXdgDataDirs, "/nix/share:/usr/share")
+	dirs = getSessionsDataDirs(c)
+	if len(dirs) != 3 || dirs[0] != "/opt/sessions" || dirs[1] != "/usr/share" || dirs[2] != "/nix/share" {
+		t.Errorf("TestGetSessionsDataDirs: unexpected data dirs %v", dirs)
+	}
+}
+
+func TestGetDesktopFileId(t *testing.T) {
+	if id := getDesktopFileId("/usr/share/xsessions/", "/usr/share/xsessions/vendor/desktop.desktop"); id != "vendor-desktop.desktop" {
+		t.Errorf("TestGetDesktopFileId: unexpected desktop file ID '%s'", id)
+	}
+}
+
 func TestIsLastDesktopForSave(t *testing.T) {
 	currentDesktop := &desktop{exec: "/usr/bin/none", env: Wayland}
 	lastDesktop := &desktop{exec: "/usr/bin/none", env: Wayland}
@@ -197,7 +343,7 @@ func TestListAllDesktops(t *testing.T) {
 	usr := &sysuser{}
 	usr.homedir = getTestingPath("userHome2")
 
-	desktops := listAllDesktops(usr, getTestingPath("desktops"), getTestingPath("desktops"))
+	desktops := listAllDesktops(usr, []string{getTestingPath("desktops")}, []string{getTestingPath("desktops")}, "")
 
 	if len(desktops) != 6 {
 		t.Error("TestListAllDesktops: unexpected count of desktops, 6 expected")
@@ -205,7 +351,7 @@ func TestListAllDesktops(t *testing.T) {
 
 	usr.homedir = "/dev/null"
 
-	desktops = listAllDesktops(usr, "/dev/null", "/dev/null")
+	desktops = listAllDesktops(usr, []string{"/dev/null"}, []string{"/dev/null"}, "")
 	if len(desktops) != 0 {
 		t.Error("TestListAllDesktops: unexpected count of desktops, 0 expected")
 	}
@@ -2,8 +2,10 @@ package src
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"strings"
+	"sync/atomic"
 )
 
 const version = "0.5.0"
@@ -21,7 +23,68 @@ func Main() {
 		os.Exit(0)
 	}
 
-	conf := loadConfig(pathConfigFile)
+	if isLoginWorker() {
+		runWorker()
+	}
+
+	conf := loadAppConfig()
+	loopMode = conf.daemonMode && conf.daemonLoop
+
+	initialized := false
+	for {
+		var fTTY *os.File
+		var vt *vtState
+		previousTTY := 0
+		if conf.daemonMode {
+			previousTTY, _ = getActiveTTY()
+			fTTY = startDaemon(conf)
+			// VT state is restored only if it could be read
+			vt, _ = getVtState(fTTY)
+		}
+
+		if !initialized {
+			initLogger(conf)
+			initialized = true
+		}
+		printMotd(conf)
+		var aborted bool
+		if loopMode {
+			aborted = runLoginWorker(conf, fTTY)
+		} else {
+			aborted = runLogin(conf)
+		}
+
+		if conf.daemonMode {
+			if vt != nil {
+				if err := restoreVtState(fTTY, vt); err != nil {
+					log.Print(err)
+				}
+			}
+			stopDaemon(conf, fTTY)
+			if conf.ttyAuto && conf.disallocateTTY {
+				if err := disallocateTTY(conf.tty, previousTTY); err != nil {
+					log.Print(err)
+				}
+			}
+		}
+
+		if !loopMode || atomic.LoadInt32(&interrupted) != 0 {
+			if aborted && !loopMode {
+				os.Exit(1)
+			}
+			break
+		}
+
+		// Start next iteration with clean configuration
+		conf = loadAppConfig()
+		loopMode = conf.daemonMode && conf.daemonLoop
+		log.Print("Returning to login prompt")
+	}
+}
+
+// Loads application configuration and applies overrides from arguments.
+func loadAppConfig() *config {
+	conf := loadConfig(getConfigPath(os.Args))
 
 	for i, arg := range os.Args {
 		switch arg {
@@ -30,6 +93,10 @@ func Main() {
 				tty := parseTTY(os.Args[i+1], "0")
 				if tty > 0 {
 					conf.tty = tty
+					conf.ttyAuto = false
+				} else if isTTYAuto(os.Args[i+1]) {
+					conf.tty = 0
+					conf.ttyAuto = true
 				}
 			}
 		case "-d", "--daemon":
@@ -37,18 +104,41 @@ func Main() {
 		}
 	}
 
-	var fTTY *os.File
-	if conf.daemonMode {
-		fTTY = startDaemon(conf)
-	}
+	return conf
+}
 
-	initLogger(conf)
-	printMotd(conf)
-	login(conf)
+// Runs login. Handled error aborts current login, its state is cleaned up and true is returned.
+func runLogin(conf *config) (aborted bool) {
+	var auth authenticator
+	loginRunning = true
+	defer func() {
+		loginRunning = false
+		if r := recover(); r != nil {
+			if _, ok := r.(*abortedLogin); !ok {
+				panic(r)
+			}
+			if auth != nil {
+				closeAuth(auth)
+			}
+			log.Print("Login aborted")
+			aborted = true
+		}
+	}()
 
-	if conf.daemonMode {
-		stopDaemon(conf, fTTY)
+	auth, err := newAuthenticator(conf)
+	handleErr(err)
+	login(conf, auth)
+	return false
+}
+
+// Gets path of configuration file, that could be overridden by argument.
+func getConfigPath(args []string) string {
+	for i, arg := range args {
+		if (arg == "-c" || arg == "--config") && len(args) > i+1 {
+			return args[i+1]
+		}
 	}
+	return pathConfigFile
 }
 
 // Prints help
@@ -58,7 +148,8 @@ func printHelp() {
 	fmt.Printf("  -h, --help\t\tprint this help\n")
 	fmt.Printf("  -v, --version\t\tprint version\n")
 	fmt.Printf("  -d, --daemon\t\tstart in daemon mode\n")
-	fmt.Printf("  -t, --tty NUMBER\toverrides configured TTY number\n")
+	fmt.Printf("  -t, --tty NUMBER\toverrides configured TTY number, could be also auto\n")
+	fmt.Printf("  -c, --config PATH\tloads configuration from PATH instead of %s\n", pathConfigFile)
 }
 
 // Gets current version
@@ -27,3 +27,15 @@ func TestPrintHelp(t *testing.T) {
 		t.Error("TestPrintHelp: help does not return text")
 	}
 }
+
+func TestGetConfigPath(t *testing.T) {
+	if getConfigPath([]string{"emptty", "-d"}) != pathConfigFile {
+		t.Error("TestGetConfigPath: default path was expected")
+	}
+	if getConfigPath([]string{"emptty", "-d", "--config", "/etc/emptty/tty2"}) != "/etc/emptty/tty2" {
+		t.Error("TestGetConfigPath: path from argument was expected")
+	}
+	if getConfigPath([]string{"emptty", "-c"}) != pathConfigFile {
+		t.Error("TestGetConfigPath: missing path should fall back to default path")
+	}
+}
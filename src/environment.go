@@ -0,0 +1,259 @@
+package src
+
+import (
+	"bufio"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	pathEtcEnvironment      = "/etc/environment"
+	pathUserEnvironmentD    = "/.config/environment.d/"
+	suffixEnvironmentDFiles = ".conf"
+)
+
+// sessionEnvVars defines variables identifying the session, that could not be overridden by environment files.
+var sessionEnvVars = []string{envHome, envPwd, envUser, envLogname, envShell, envXdgSessionId, envXdgSessionClass, envXdgSeat, envXdgVtnr, envXdgRuntimeDir}
+
+// pathsEnvironmentD defines system-wide environment.d directories ordered by their priority.
+var pathsEnvironmentD = []string{"/etc/environment.d/", "/run/environment.d/", "/usr/local/lib/environment.d/", "/usr/lib/environment.d/"}
+
+// Loads /etc/environment and environment.d files into user's environment.
+// Files are read with user's credentials, environment.d files override variables from /etc/environment.
+// LANG defined by emptty or user configuration is kept.
+func loadEnvironmentFiles(usr *sysuser, conf *config) {
+	keptEnvVars := sessionEnvVars
+	if conf.langDefined {
+		keptEnvVars = append(append([]string{}, sessionEnvVars...), envLang)
+	}
+	doAsUser(usr, func() {
+		readEnvironmentFiles(usr, pathEtcEnvironment, usr.homedir+pathUserEnvironmentD, pathsEnvironmentD, keptEnvVars, conf.deniedEnvVars)
+	})
+	log.Print("Loaded environment files")
+}
+
+// Reads environment file defined by etcPath and then environment.d files from userDir and systemDirs into user's environment.
+// Kept variables stay as defined by emptty, files from userDir could not define denied variables.
+func readEnvironmentFiles(usr *sysuser, etcPath string, userDir string, systemDirs []string, keptEnvVars []string, deniedEnvVars []string) {
+	denied := keptEnvVars
+	setenv := func(key string, value string) {
+		if contains(denied, key) {
+			log.Print("Skipped environmental variable " + key)
+			return
+		}
+		usr.setenv(key, value)
+	}
+
+	if fileExists(etcPath) {
+		if err := readEnvironmentFile(etcPath, false, usr.getenv, setenv); err != nil {
+			log.Print(err)
+		}
+	}
+
+	userDenied := append(append([]string{}, keptEnvVars...), deniedEnvVars...)
+	for _, path := range listEnvironmentDFiles(append([]string{userDir}, systemDirs...)) {
+		denied = keptEnvVars
+		if strings.HasPrefix(path, userDir) {
+			denied = userDenied
+		}
+		if err := readEnvironmentFile(path, true, usr.getenv, setenv); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// Lists environment.d files from all dirs sorted by their names.
+// If file with the same name exists in more dirs, the one from dir defined earlier is used.
+func listEnvironmentDFiles(dirs []string) []string {
+	files := make(map[string]string)
+	var names []string
+
+	for _, dir := range dirs {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			name := info.Name()
+			if info.IsDir() || !strings.HasSuffix(name, suffixEnvironmentDFiles) {
+				continue
+			}
+			if _, ok := files[name]; !ok {
+				files[name] = dir + name
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	var result []string
+	for _, name := range names {
+		result = append(result, files[name])
+	}
+	return result
+}
+
+// Reads environment file per line and sets each valid assignment.
+// If expand is true, variables in values are expanded with getenv.
+func readEnvironmentFile(path string, expand bool, getenv func(string) string, setenv func(string, string)) error {
+	return readEnvironmentAssignments(path, func(key string, value string, exported bool) {
+		if expand {
+			value = expandEnvValue(value, getenv)
+		}
+		setenv(key, value)
+	})
+}
+
+// Reads only assignments prefixed with "export " from file, e.g. from user config or script.
+func readExportedVariables(path string, method func(key string, value string)) error {
+	return readEnvironmentAssignments(path, func(key string, value string, exported bool) {
+		if exported {
+			method(key, value)
+		}
+	})
+}
+
+// Reads file per line and invokes method for each valid assignment of environmental variable.
+func readEnvironmentAssignments(path string, method func(key string, value string, exported bool)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.Index(line, "=") < 0 {
+			continue
+		}
+
+		exported := strings.HasPrefix(line, "export ")
+		line = strings.TrimPrefix(line, "export ")
+
+		splitIndex := strings.Index(line, "=")
+		key := strings.TrimSpace(line[:splitIndex])
+		if !isValidEnvKey(key) {
+			continue
+		}
+
+		method(key, unquoteEnvValue(strings.TrimSpace(line[splitIndex+1:])), exported)
+	}
+	return scanner.Err()
+}
+
+// Applies variables defined by desktop and its child into user's environment, user's own desktop has the highest priority.
+// Variables are expanded with already defined environment, denied variables are skipped.
+func applyDesktopEnvVars(usr *sysuser, d *desktop, deniedEnvVars []string) {
+	var envVars []string
+	if d.child != nil {
+		envVars = append(envVars, d.child.envVars...)
+	}
+	envVars = append(envVars, d.envVars...)
+
+	for _, envVar := range envVars {
+		splitIndex := strings.Index(envVar, "=")
+		key := envVar[:splitIndex]
+		if contains(deniedEnvVars, key) {
+			log.Print("Skipped denied environmental variable " + key)
+			continue
+		}
+		usr.setenv(key, expandEnvValue(envVar[splitIndex+1:], usr.getenv))
+	}
+}
+
+// Checks, if key is valid name of environmental variable.
+func isValidEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Removes matching single or double quotes surrounding value.
+func unquoteEnvValue(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// Expands $VAR, ${VAR}, ${VAR:-default} and ${VAR:+alternative} in value, "\$" is kept as "$".
+func expandEnvValue(value string, getenv func(string) string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && i+1 < len(value) && (value[i+1] == '$' || value[i+1] == '\\'):
+			sb.WriteByte(value[i+1])
+			i++
+		case c == '$' && i+1 < len(value) && value[i+1] == '{':
+			end := findClosingBrace(value[i:])
+			if end < 0 {
+				sb.WriteString(value[i:])
+				return sb.String()
+			}
+			sb.WriteString(expandEnvExpression(value[i+2:i+end], getenv))
+			i += end
+		case c == '$':
+			j := i + 1
+			for j < len(value) && isValidEnvKey(value[i+1:j+1]) {
+				j++
+			}
+			if j == i+1 {
+				sb.WriteByte(c)
+			} else {
+				sb.WriteString(getenv(value[i+1 : j]))
+				i = j - 1
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String()
+}
+
+// Finds index of brace closing expression starting at the beginning of value, or -1 if it is not closed.
+func findClosingBrace(value string) int {
+	depth := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Evaluates content of ${...} expression.
+func expandEnvExpression(expr string, getenv func(string) string) string {
+	if index := strings.Index(expr, ":-"); index >= 0 {
+		if value := getenv(expr[:index]); value != "" {
+			return value
+		}
+		return expandEnvValue(expr[index+2:], getenv)
+	}
+	if index := strings.Index(expr, ":+"); index >= 0 {
+		if getenv(expr[:index]) != "" {
+			return expandEnvValue(expr[index+2:], getenv)
+		}
+		return ""
+	}
+	return getenv(expr)
+}
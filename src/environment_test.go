@@ -0,0 +1,121 @@
+package src
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListEnvironmentDFiles(t *testing.T) {
+	files := listEnvironmentDFiles([]string{getTestingPath("environment/user.d/"), getTestingPath("environment/system.d/"), getTestingPath("environment/missing.d/")})
+
+	if len(files) != 3 {
+		t.Fatalf("TestListEnvironmentDFiles: unexpected files %v", files)
+	}
+	if !strings.HasSuffix(files[0], "system.d/10-base.conf") || !strings.HasSuffix(files[1], "user.d/20-shadowed.conf") {
+		t.Errorf("TestListEnvironmentDFiles: unexpected order or shadowing of files %v", files)
+	}
+}
+
+func TestReadEnvironmentFile(t *testing.T) {
+	u := &sysuser{homedir: "/home/emptty", env: map[string]string{"HOME": "/home/emptty", "PATH": "/usr/bin"}}
+
+	err := readEnvironmentFile(getTestingPath("environment/environment"), false, u.getenv, u.setenv)
+	if err != nil {
+		t.Fatalf("TestReadEnvironmentFile: %v", err)
+	}
+	if u.getenv("EDITOR") != "vi" || u.getenv("QUOTED") != "quoted value" || u.getenv("EXPORTED") != "yes" || u.getenv("NOT_EXPANDED") != "$HOME" {
+		t.Errorf("TestReadEnvironmentFile: unexpected environment %v", u.env)
+	}
+
+	for _, path := range listEnvironmentDFiles([]string{getTestingPath("environment/user.d/"), getTestingPath("environment/system.d/")}) {
+		readEnvironmentFile(path, true, u.getenv, u.setenv)
+	}
+	if u.getenv("PATH") != "/opt/bin:/usr/bin" || u.getenv("EDITOR") != "nano" || u.getenv("SHADOWED") != "user" {
+		t.Errorf("TestReadEnvironmentFile: unexpected environment.d values %v", u.env)
+	}
+	if u.getenv("XDG_DATA_DIRS") != "/home/emptty/.local/share:/usr/local/share:/usr/share" || u.getenv("BROWSER") != "" || u.getenv("PRICE") != "$5" {
+		t.Errorf("TestReadEnvironmentFile: unexpected expanded values %v", u.env)
+	}
+	if _, ok := u.env["invalid-key"]; ok {
+		t.Error("TestReadEnvironmentFile: invalid key should be skipped")
+	}
+}
+
+func TestReadEnvironmentFiles(t *testing.T) {
+	u := &sysuser{homedir: "/home/emptty", env: map[string]string{"HOME": "/home/emptty", "XDG_RUNTIME_DIR": "/run/user/1000", "LANG": "en_US.UTF-8"}}
+
+	readEnvironmentFiles(u, getTestingPath("environment/environment"), getTestingPath("environment/user.d/"), []string{getTestingPath("environment/system.d/")}, sessionEnvVars, []string{"LD_PRELOAD", "PATH"})
+	if u.getenv("EDITOR") != "nano" || u.getenv("SESSION_FILE") != "loaded" {
+		t.Errorf("TestReadEnvironmentFiles: environment files were not loaded %v", u.env)
+	}
+	if u.getenv("LD_PRELOAD") != "" || u.getenv("PATH") != "/opt/bin:" {
+		t.Errorf("TestReadEnvironmentFiles: denied variables should be defined only by system files %v", u.env)
+	}
+	if u.getenv("HOME") != "/home/emptty" || u.getenv("XDG_RUNTIME_DIR") != "/run/user/1000" {
+		t.Errorf("TestReadEnvironmentFiles: session variables should not be overridden %v", u.env)
+	}
+	if u.getenv("LANG") != "cs_CZ.UTF-8" {
+		t.Errorf("TestReadEnvironmentFiles: LANG should be loaded, if it is not kept %v", u.env)
+	}
+
+	u.setenv("LANG", "en_US.UTF-8")
+	readEnvironmentFiles(u, getTestingPath("environment/environment"), getTestingPath("environment/user.d/"), []string{getTestingPath("environment/system.d/")}, append(append([]string{}, sessionEnvVars...), "LANG"), nil)
+	if u.getenv("LANG") != "en_US.UTF-8" {
+		t.Errorf("TestReadEnvironmentFiles: kept LANG should not be overridden %v", u.env)
+	}
+}
+
+func TestExpandEnvValue(t *testing.T) {
+	getenv := func(key string) string {
+		return map[string]string{"A": "a", "AB": "ab"}[key]
+	}
+
+	values := map[string]string{
+		"$A":          "a",
+		"$AB/$A":      "ab/a",
+		"${A}B":       "aB",
+		"${C:-c}":     "c",
+		"${A:-c}":     "a",
+		"${A:+x$A}":   "xa",
+		"${C:+x}":     "",
+		"$":           "$",
+		"\\$A":        "$A",
+		"${A":         "${A",
+		"a$-b":        "a$-b",
+		"${C:-${AB}}": "ab",
+	}
+
+	for value, expected := range values {
+		if result := expandEnvValue(value, getenv); result != expected {
+			t.Errorf("TestExpandEnvValue: '%s' was expanded to '%s', expected '%s'", value, result, expected)
+		}
+	}
+}
+
+func TestIsValidEnvKey(t *testing.T) {
+	for _, key := range []string{"PATH", "_A1", "a_b"} {
+		if !isValidEnvKey(key) {
+			t.Errorf("TestIsValidEnvKey: key '%s' should be valid", key)
+		}
+	}
+	for _, key := range []string{"", "1A", "A-B", "A B"} {
+		if isValidEnvKey(key) {
+			t.Errorf("TestIsValidEnvKey: key '%s' should not be valid", key)
+		}
+	}
+}
+
+func TestApplyDesktopEnvVars(t *testing.T) {
+	u := &sysuser{env: map[string]string{"USER": "emptty", "PATH": "/usr/bin"}}
+	d := &desktop{envVars: []string{"GREETING=hello ${USER}", "PATH=/tmp", "SHARED=user"}}
+	d.child = &desktop{envVars: []string{"SHARED=session", "LD_PRELOAD=/tmp/lib.so", "SESSION=child"}}
+
+	applyDesktopEnvVars(u, d, []string{"PATH", "LD_PRELOAD"})
+
+	if u.getenv("GREETING") != "hello emptty" || u.getenv("SHARED") != "user" || u.getenv("SESSION") != "child" {
+		t.Errorf("TestApplyDesktopEnvVars: unexpected environment %v", u.env)
+	}
+	if u.getenv("PATH") != "/usr/bin" || u.getenv("LD_PRELOAD") != "" {
+		t.Errorf("TestApplyDesktopEnvVars: denied variables were applied %v", u.env)
+	}
+}
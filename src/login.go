@@ -1,14 +1,18 @@
 package src
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
 const (
@@ -18,6 +22,8 @@ const (
 	envXdgSessionType  = "XDG_SESSION_TYPE"
 	envXdgSessionClass = "XDG_SESSION_CLASS"
 	envXdgSeat         = "XDG_SEAT"
+	envXdgVtnr         = "XDG_VTNR"
+	envXdgDataDirs     = "XDG_DATA_DIRS"
 	envHome            = "HOME"
 	envPwd             = "PWD"
 	envUser            = "USER"
@@ -29,15 +35,34 @@ const (
 	envPath            = "PATH"
 	envDesktopSession  = "DESKTOP_SESSION"
 	envXdgSessDesktop  = "XDG_SESSION_DESKTOP"
+	envXdgCurrDesktop  = "XDG_CURRENT_DESKTOP"
+
+	// loginLockoutDelay defines in seconds, how long is login locked after MAX_LOGIN_ATTEMPTS is reached in loop mode
+	loginLockoutDelay = 30
 )
 
+// interrupted is set, if emptty caught interrupt signal during running session.
+var interrupted int32
+
+// failedLoginAttempts counts failed attempts since the last successful authentication, it is kept across login loop.
+var failedLoginAttempts int
+
 // Login into graphical environment
-func login(conf *config) {
-	usr := authUser(conf)
+func login(conf *config, auth authenticator) {
+	usr := authUser(conf, auth)
+	startSession(usr, conf, auth)
+}
 
+// Starts session of authenticated user and cleans it up after it is finished or aborted.
+func startSession(usr *sysuser, conf *config, auth authenticator) {
 	var d *desktop
 	d, usrLang := loadUserDesktop(usr.homedir)
 
+	if usrLang != "" {
+		conf.lang = usrLang
+		conf.langDefined = true
+	}
+
 	if d == nil || (d != nil && d.selection) {
 		selectedDesktop := selectDesktop(usr, conf)
 		if d != nil && d.selection {
@@ -48,37 +73,64 @@ func login(conf *config) {
 		}
 	}
 
-	if usrLang != "" {
-		conf.lang = usrLang
-	}
+	defineSpecificEnvVariables(usr, auth)
+	session := openLogindSession(usr, d, conf)
+
+	// Session is cleaned up also if login is aborted by error
+	displayScriptStarted := false
+	defer func() {
+		closeAuth(auth)
+		session.release()
+		releaseRuntimeDir(usr)
+
+		if displayScriptStarted {
+			runDisplayScript(conf.displayStopScript)
+		}
+	}()
 
 	defineEnvironment(usr, conf, d)
 
 	runDisplayScript(conf.displayStartScript)
+	displayScriptStarted = true
 
 	switch d.env {
 	case Wayland:
 		wayland(usr, d, conf)
 	case Xorg:
 		xorg(usr, d, conf)
+	case Console:
+		console(usr, d, conf)
 	}
+}
 
-	closeAuth()
-
-	runDisplayScript(conf.displayStopScript)
+// Handles failed login attempt. If MAX_LOGIN_ATTEMPTS is reached, error is handled as fatal,
+// in loop mode the login is locked for loginLockoutDelay before; otherwise user is informed and could try it again.
+func handleLoginFailure(conf *config, attempt int, err error) {
+	if conf.maxLoginAttempts > 0 && attempt >= conf.maxLoginAttempts {
+		if loopMode {
+			// login prompt is locked, so attempts could not continue in next iteration of loop without delay
+			log.Printf("Reached %d failed login attempts, login is locked for %ds", attempt, loginLockoutDelay)
+			fmt.Printf("\nLogin incorrect, too many failed attempts. Try again in %d seconds.\n", loginLockoutDelay)
+			time.Sleep(loginLockoutDelay * time.Second)
+		}
+		handleErr(err)
+	}
+	log.Print(err)
+	fmt.Printf("\nLogin incorrect\n\n")
 }
 
 // Prepares environment and env variables for authorized user.
 func defineEnvironment(usr *sysuser, conf *config, d *desktop) {
-	defineSpecificEnvVariables(usr)
-
 	usr.setenv(envHome, usr.homedir)
 	usr.setenv(envPwd, usr.homedir)
 	usr.setenv(envUser, usr.username)
 	usr.setenv(envLogname, usr.username)
 	usr.setenv(envXdgConfigHome, usr.homedir+"/.config")
-	usr.setenv(envXdgRuntimeDir, "/run/user/"+usr.strUid())
-	usr.setenv(envXdgSeat, "seat0")
+	usr.runtimeDir = getRuntimeDir(usr)
+	usr.setenv(envXdgRuntimeDir, usr.runtimeDir)
+	if usr.getenv(envXdgSeat) == "" {
+		usr.setenv(envXdgSeat, "seat0")
+	}
 	usr.setenv(envXdgSessionClass, "user")
 	shell := getUserShell(usr)
 	if shell == "" {
@@ -87,39 +139,62 @@ func defineEnvironment(usr *sysuser, conf *config, d *desktop) {
 	usr.setenv(envShell, shell)
 	usr.setenv(envLang, conf.lang)
 	usr.setenv(envPath, os.Getenv(envPath))
-
-	if d.name != "" {
-		usr.setenv(envDesktopSession, d.name)
-		usr.setenv(envXdgSessDesktop, d.name)
-	} else if d.child != nil && d.child.name != "" {
-		usr.setenv(envDesktopSession, d.child.name)
-		usr.setenv(envXdgSessDesktop, d.child.name)
+	loadEnvironmentFiles(usr, conf)
+	applyDesktopEnvVars(usr, d, conf.deniedEnvVars)
+	// runtime dir is created and removed by root, so user could not redefine it
+	usr.setenv(envXdgRuntimeDir, usr.runtimeDir)
+
+	if d.env != Console {
+		if d.name != "" {
+			usr.setenv(envDesktopSession, d.name)
+			usr.setenv(envXdgSessDesktop, d.name)
+		} else if d.child != nil && d.child.name != "" {
+			usr.setenv(envDesktopSession, d.child.name)
+			usr.setenv(envXdgSessDesktop, d.child.name)
+		}
+		if currentDesktop := getXdgCurrentDesktop(d); currentDesktop != "" {
+			usr.setenv(envXdgCurrDesktop, currentDesktop)
+		}
 	}
 
 	log.Print("Defined Environment")
 
 	// create XDG folder
-	if !fileExists(usr.getenv(envXdgRuntimeDir)) {
-		err := os.MkdirAll(usr.getenv(envXdgRuntimeDir), 0700)
-		handleErr(err)
+	prepareRuntimeDir(usr)
 
-		// Set owner of XDG folder
-		os.Chown(usr.getenv(envXdgRuntimeDir), usr.uid, usr.gid)
+	os.Chdir(usr.getenv(envPwd))
+}
 
-		log.Print("Created XDG folder")
-	} else {
-		log.Print("XDG folder already exists, no need to create")
+// Gets value of XDG_CURRENT_DESKTOP for desktop. DesktopNames defined by user config have the highest priority,
+// then DesktopNames of selected session; if none is defined, name of session is used.
+func getXdgCurrentDesktop(d *desktop) string {
+	if d.desktopNames != "" {
+		return d.desktopNames
 	}
-
-	os.Chdir(usr.getenv(envPwd))
+	if d.child != nil && d.child.desktopNames != "" {
+		return d.child.desktopNames
+	}
+	if d.name != "" {
+		return d.name
+	}
+	if d.child != nil {
+		return d.child.name
+	}
+	return ""
 }
 
-// Reads default shell of authorized user.
+// Reads default shell of authorized user. If it could not be read, empty string is returned.
 func getUserShell(usr *sysuser) string {
 	out, err := exec.Command("/usr/bin/getent", "passwd", usr.strUid()).Output()
-	handleErr(err)
+	if err != nil {
+		log.Print(err)
+		return ""
+	}
 
 	ent := strings.Split(strings.TrimSuffix(string(out), "\n"), ":")
+	if len(ent) < 7 {
+		return ""
+	}
 	shellCmdline := ent[6]
 	info, err := os.Stat(shellCmdline)
 	if err != nil || info.IsDir() {
@@ -137,7 +212,13 @@ func wayland(usr *sysuser, d *desktop, conf *config) {
 
 	// start Wayland
 	wayland, strExec := prepareGuiCommand(usr, d, conf)
-	registerInterruptHandler(wayland)
+	if sessionLog := openSessionLog(usr, conf.sessionLog); sessionLog != nil {
+		wayland.Stdout = sessionLog
+		wayland.Stderr = sessionLog
+		defer sessionLog.Close()
+	}
+	interrupt := registerInterruptHandler(wayland)
+	defer stopInterruptHandler(interrupt)
 	log.Print("Starting " + strExec)
 	err := wayland.Start()
 	handleErr(err)
@@ -145,41 +226,98 @@ func wayland(usr *sysuser, d *desktop, conf *config) {
 	// make utmp entry
 	utmpEntry := addUtmpEntry(usr.username, wayland.Process.Pid, conf.strTTY(), "")
 	log.Print("Added utmp entry")
+	defer func() {
+		endUtmpEntry(utmpEntry)
+		log.Print("Ended utmp entry")
+	}()
 
 	wayland.Wait()
 	log.Print(strExec + " finished")
+}
+
+// Prepares and starts console session with user's login shell on emptty TTY.
+func console(usr *sysuser, d *desktop, conf *config) {
+	// Set environment
+	usr.setenv(envXdgSessionType, "tty")
+	log.Print("Defined console environment")
+
+	// start shell
+	shell := prepareConsoleCommand(usr, d)
+	pgrp, err := getForegroundProcessGroup(os.Stdin.Fd())
+	if err == nil {
+		shell.SysProcAttr.Foreground = true
+		shell.SysProcAttr.Ctty = int(os.Stdin.Fd())
+	}
+	interrupt := registerInterruptHandler(shell)
+	defer stopInterruptHandler(interrupt)
+	log.Print("Starting " + shell.Path)
+	err = shell.Start()
+	handleErr(err)
+
+	// make utmp entry
+	utmpEntry := addUtmpEntry(usr.username, shell.Process.Pid, conf.strTTY(), "")
+	log.Print("Added utmp entry")
+	defer func() {
+		endUtmpEntry(utmpEntry)
+		log.Print("Ended utmp entry")
+	}()
+
+	shell.Wait()
+	log.Print(shell.Path + " finished")
+
+	// take TTY back from finished shell
+	if shell.SysProcAttr.Foreground {
+		if err := setForegroundProcessGroup(os.Stdin.Fd(), pgrp); err != nil {
+			log.Print(err)
+		}
+	}
+}
 
-	// end utmp entry
-	endUtmpEntry(utmpEntry)
-	log.Print("Ended utmp entry")
+// Prepares command for starting console session. If desktop has defined exec, it is started by login shell;
+// otherwise interactive login shell is started.
+func prepareConsoleCommand(usr *sysuser, d *desktop) *exec.Cmd {
+	shell := usr.getenv(envShell)
+	if shell == "" {
+		shell = "/usr/bin/bash"
+	}
+
+	var cmd *exec.Cmd
+	if d.exec != "" {
+		cmd = cmdAsUser(usr, shell, "--login", "-c", d.exec)
+	} else {
+		cmd = cmdAsUser(usr, shell)
+		cmd.Args[0] = "-" + filepath.Base(shell)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
 }
 
 // Prepares and starts Xorg session for authorized user.
 func xorg(usr *sysuser, d *desktop, conf *config) {
-	freeDisplay := strconv.Itoa(getFreeXDisplay())
+	display, err := getFreeXDisplay()
+	handleErr(err)
+	freeDisplay := strconv.Itoa(display)
 
 	// Set environment
 	usr.setenv(envXdgSessionType, "x11")
-	usr.setenv(envXauthority, usr.getenv(envXdgRuntimeDir)+"/.emptty-xauth")
+	usr.setenv(envXauthority, usr.runtimeDir+"/.emptty-xauth")
 	usr.setenv(envDisplay, ":"+freeDisplay)
 	os.Setenv(envXauthority, usr.getenv(envXauthority))
 	os.Setenv(envDisplay, usr.getenv(envDisplay))
 	log.Print("Defined Xorg environment")
 
-	// create xauth
-	os.Remove(usr.getenv(envXauthority))
-
-	// generate mcookie
-	cmd := cmdAsUser(usr, "/usr/bin/mcookie")
-	mcookie, err := cmd.Output()
-	handleErr(err)
-	log.Print("Generated mcookie")
-
 	// generate xauth
-	cmd = cmdAsUser(usr, "/usr/bin/xauth", "add", usr.getenv(envDisplay), ".", string(mcookie))
-	_, err = cmd.Output()
+	defer func() {
+		os.Remove(usr.getenv(envXauthority))
+		os.Unsetenv(envXauthority)
+		os.Unsetenv(envDisplay)
+		log.Print("Cleaned up xauthority")
+	}()
+
+	err = generateXauthority(usr, usr.getenv(envXauthority), usr.getenv(envDisplay))
 	handleErr(err)
-
 	log.Print("Generated xauthority")
 
 	// start X
@@ -192,48 +330,180 @@ func xorg(usr *sysuser, d *desktop, conf *config) {
 		xorgArgs = append(xorgArgs, arrXorgArgs...)
 	}
 
-	xorg := exec.Command("/usr/bin/Xorg", xorgArgs...)
-	xorg.Env = append(os.Environ())
-	xorg.Start()
-	if xorg.Process == nil {
-		handleStrErr("Xorg is not running")
+	xorg, restoreTTY, rootless := prepareXorgCommand(usr, conf, xorgArgs)
+	defer restoreTTY()
+
+	xorgLogPath := getXorgLogPath(usr, display, rootless)
+	xorg.Stdout = log.Writer()
+	xorg.Stderr = log.Writer()
+	if conf.xorgLog {
+		if rootless {
+			xorgLogPath = prepareXorgLog(usr, display)
+			xorg.Args = append(xorg.Args, "-logfile", xorgLogPath)
+		} else if xorgLog := openXorgLog(usr, display); xorgLog != nil {
+			// Xorg started as root must not write into user's home directory, its output is captured instead
+			xorg.Args = append(xorg.Args, "-verbose", strconv.Itoa(xorgLogVerbosity))
+			xorg.Stdout = xorgLog
+			xorg.Stderr = xorgLog
+			defer xorgLog.Close()
+		}
+	}
+	xorgExited, err := startXorg(xorg, display, time.Duration(conf.xorgStartTimeout)*time.Second)
+	if err != nil {
+		logTail := ""
+		if rootless {
+			doAsUser(usr, func() {
+				logTail = getXorgLogTail(xorgLogPath, xorgLogTail)
+			})
+		} else {
+			logTail = getXorgLogTail(xorgLogPath, xorgLogTail)
+		}
+		if logTail != "" {
+			err = fmt.Errorf("%v\n%s", err, logTail)
+		}
+		handleErr(err)
 	}
 	log.Print("Started Xorg")
+	defer func() {
+		xorg.Process.Signal(os.Interrupt)
+		<-xorgExited
+		log.Print("Interrupted Xorg")
+	}()
 
 	disp := &xdisplay{}
 	disp.dispName = usr.getenv(envDisplay)
-	handleErr(disp.openXDisplay())
+	err = disp.openXDisplay()
+	handleErr(err)
 
 	// make utmp entry
 	utmpEntry := addUtmpEntry(usr.username, xorg.Process.Pid, conf.strTTY(), usr.getenv(envDisplay))
 	log.Print("Added utmp entry")
+	defer func() {
+		endUtmpEntry(utmpEntry)
+		log.Print("Ended utmp entry")
+	}()
 
 	// start xinit
 	xinit, strExec := prepareGuiCommand(usr, d, conf)
-	registerInterruptHandler(xinit, xorg)
+	if sessionLog := openSessionLog(usr, conf.sessionLog); sessionLog != nil {
+		xinit.Stdout = sessionLog
+		xinit.Stderr = sessionLog
+		defer sessionLog.Close()
+	}
+	interrupt := registerInterruptHandler(xinit, xorg)
+	defer stopInterruptHandler(interrupt)
 	log.Print("Starting " + strExec)
 	err = xinit.Start()
-	if err != nil {
-		xorg.Process.Signal(os.Interrupt)
-		xorg.Wait()
-		handleErr(err)
-	}
+	handleErr(err)
 
 	xinit.Wait()
 	log.Print(strExec + " finished")
+}
+
+// Prepares command for starting Xorg. In rootless mode Xorg is started as user, if TTY could be handed over to the user.
+// Returned function restores original ownership of TTY, returned bool is true, if Xorg is started as user.
+func prepareXorgCommand(usr *sysuser, conf *config, xorgArgs []string) (*exec.Cmd, func(), bool) {
+	if conf.rootlessXorg {
+		restoreTTY, err := handOverTTY(usr, "/dev/tty"+conf.strTTY())
+		if err == nil {
+			xorg := cmdAsUser(usr, "/usr/bin/Xorg", append(xorgArgs, "-keeptty", "-novtswitch")...)
+			xorg.Stdin = os.Stdin
+			log.Print("Prepared rootless Xorg")
+			return xorg, restoreTTY, true
+		}
+		log.Print("Could not hand over TTY, starting Xorg as root: ", err)
+	}
+
+	xorg := exec.Command("/usr/bin/Xorg", xorgArgs...)
+	xorg.Env = append(os.Environ())
+	return xorg, func() {}, false
+}
+
+// Gets path of log, that is written by Xorg by default. Xorg started as user writes its log into user's home directory.
+func getXorgLogPath(usr *sysuser, display int, rootless bool) string {
+	if rootless {
+		return usr.homedir + fmt.Sprintf(pathRootlessXorgLog, display)
+	}
+	return fmt.Sprintf(pathXorgLog, display)
+}
+
+// Changes ownership of TTY to user. Returned function restores its original ownership.
+func handOverTTY(usr *sysuser, path string) (func(), error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, errors.New("Could not get ownership of " + path)
+	}
+
+	if err := os.Chown(path, usr.uid, usr.gid); err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := os.Chown(path, int(stat.Uid), int(stat.Gid)); err != nil {
+			log.Print(err)
+		}
+	}, nil
+}
+
+// Prepares directory for Xorg log in user's home directory and rotates previous log of the same display.
+// It returns path to Xorg log.
+func prepareXorgLog(usr *sysuser, display int) string {
+	path := fmt.Sprintf("%s%sXorg.%d.log", usr.homedir, pathUserLogDir, display)
+	doAsUser(usr, func() {
+		err := mkDirsForFile(path, 0744)
+		if err != nil {
+			log.Print(err)
+		}
+		rotateLogFile(path)
+	})
+	return path
+}
 
-	// Stop Xorg
-	xorg.Process.Signal(os.Interrupt)
-	xorg.Wait()
-	log.Print("Interrupted Xorg")
+// Opens Xorg log in user's home directory with user's credentials, it is used for output of Xorg started as root.
+// It returns nil, if Xorg log could not be opened.
+func openXorgLog(usr *sysuser, display int) *os.File {
+	path := prepareXorgLog(usr, display)
+	var f *os.File
+	doAsUser(usr, func() {
+		var err error
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			log.Print(err)
+		}
+	})
+	return f
+}
+
+// Opens session log in user's home directory with user's credentials.
+// It returns nil, if session log is disabled or could not be opened.
+func openSessionLog(usr *sysuser, logging enLogging) *os.File {
+	if logging != Default && logging != Appending {
+		return nil
+	}
 
-	// Remove auth
-	os.Remove(usr.getenv(envXauthority))
-	log.Print("Cleaned up xauthority")
+	path := usr.homedir + pathUserLogDir + pathSessionLog
+	var f *os.File
+	doAsUser(usr, func() {
+		err := mkDirsForFile(path, 0744)
+		if err != nil {
+			log.Print(err)
+		}
+		if logging == Default {
+			rotateLogFile(path)
+		}
 
-	// End utmp entry
-	endUtmpEntry(utmpEntry)
-	log.Print("Ended utmp entry")
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			log.Print(err)
+		}
+	})
+	if f != nil {
+		log.Print("Opened session log " + path)
+	}
+	return f
 }
 
 // Prepares command for starting GUI.
@@ -285,28 +555,28 @@ func getStrExec(d *desktop) (string, bool) {
 	return d.path, false
 }
 
-// Finds free display for spawning Xorg instance.
-func getFreeXDisplay() int {
-	for i := 0; i < 32; i++ {
-		filename := fmt.Sprintf("/tmp/.X%d-lock", i)
-		if !fileExists(filename) {
-			return i
-		}
-	}
-	return 0
-}
-
 // Registers interrupt handler, that interrupts all mentioned Cmds.
-func registerInterruptHandler(cmds ...*exec.Cmd) {
-	c := make(chan os.Signal)
+// Returned channel should be passed into stopInterruptHandler, when Cmds are finished.
+func registerInterruptHandler(cmds ...*exec.Cmd) chan os.Signal {
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGHUP, syscall.SIGINT, syscall.SIGKILL, syscall.SIGQUIT, syscall.SIGTERM)
 	go handleInterrupt(c, cmds...)
+	return c
+}
+
+// Stops interrupt handler registered by registerInterruptHandler.
+func stopInterruptHandler(c chan os.Signal) {
+	signal.Stop(c)
+	close(c)
 }
 
 // Catch interrupt signal chan and interrupts all mentioned Cmds.
 func handleInterrupt(c chan os.Signal, cmds ...*exec.Cmd) {
-	<-c
+	if _, ok := <-c; !ok {
+		return
+	}
 	log.Print("Catched interrupt signal")
+	atomic.StoreInt32(&interrupted, 1)
 	for _, cmd := range cmds {
 		cmd.Process.Signal(os.Interrupt)
 		cmd.Wait()
@@ -1,8 +1,15 @@
 package src
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/user"
 	"strings"
+	"syscall"
 	"testing"
+
+	"github.com/godbus/dbus/v5"
 )
 
 func TestGetStrExec(t *testing.T) {
@@ -96,3 +103,299 @@ func TestPrepareGuiCommandXinitrc(t *testing.T) {
 		t.Errorf("TestPrepareGuiCommandXinitrc: result exec command should start with dbus-launch: '%s'", exec)
 	}
 }
+
+func TestHandleLoginFailure(t *testing.T) {
+	c := &config{maxLoginAttempts: 3}
+
+	output := readOutput(func() {
+		handleLoginFailure(c, 1, errors.New("Authentication failure"))
+	})
+	if !strings.Contains(output, "Login incorrect") {
+		t.Errorf("TestHandleLoginFailure: unexpected output: '%s'", output)
+	}
+
+	loginRunning = true
+	var aborted interface{}
+	readOutput(func() {
+		defer func() {
+			aborted = recover()
+		}()
+		handleLoginFailure(c, 3, errors.New("Authentication failure"))
+	})
+	loginRunning = false
+	if _, ok := aborted.(*abortedLogin); !ok {
+		t.Error("TestHandleLoginFailure: reached MAX_LOGIN_ATTEMPTS should abort login")
+	}
+
+	c.maxLoginAttempts = 0
+	output = readOutput(func() {
+		handleLoginFailure(c, 100, errors.New("Authentication failure"))
+	})
+	if !strings.Contains(output, "Login incorrect") {
+		t.Errorf("TestHandleLoginFailure: unlimited attempts should not end login: '%s'", output)
+	}
+}
+
+func TestGetXdgCurrentDesktop(t *testing.T) {
+	d := &desktop{name: "Plasma"}
+	if getXdgCurrentDesktop(d) != "Plasma" {
+		t.Error("TestGetXdgCurrentDesktop: name of session was expected")
+	}
+
+	d.desktopNames = "KDE"
+	if getXdgCurrentDesktop(d) != "KDE" {
+		t.Error("TestGetXdgCurrentDesktop: DesktopNames was expected")
+	}
+
+	d = &desktop{selection: true, child: &desktop{name: "GNOME Classic", desktopNames: "GNOME-Classic:GNOME"}}
+	if getXdgCurrentDesktop(d) != "GNOME-Classic:GNOME" {
+		t.Error("TestGetXdgCurrentDesktop: DesktopNames of selected session was expected")
+	}
+
+	d.desktopNames = "sway"
+	if getXdgCurrentDesktop(d) != "sway" {
+		t.Error("TestGetXdgCurrentDesktop: DesktopNames of user config should override selected session")
+	}
+}
+
+func TestPrepareConsoleCommand(t *testing.T) {
+	u := &sysuser{uid: 3000, gid: 2000, env: make(map[string]string)}
+	u.setenv(envShell, "/bin/zsh")
+	d := &desktop{env: Console}
+
+	cmd := prepareConsoleCommand(u, d)
+	if cmd.Path != "/bin/zsh" || len(cmd.Args) != 1 || cmd.Args[0] != "-zsh" {
+		t.Errorf("TestPrepareConsoleCommand: login shell was expected: %s %v", cmd.Path, cmd.Args)
+	}
+
+	d.exec = "tmux"
+	cmd = prepareConsoleCommand(u, d)
+	if strings.Join(cmd.Args, " ") != "/bin/zsh --login -c tmux" {
+		t.Errorf("TestPrepareConsoleCommand: exec should be started by login shell: %v", cmd.Args)
+	}
+}
+
+func TestPrepareXorgCommand(t *testing.T) {
+	u := &sysuser{uid: 3000, gid: 2000, env: make(map[string]string)}
+	c := &config{tty: 999}
+
+	xorg, restoreTTY, rootless := prepareXorgCommand(u, c, []string{"vt999", ":1"})
+	restoreTTY()
+	if xorg.SysProcAttr != nil || rootless || strings.Join(xorg.Args, " ") != "/usr/bin/Xorg vt999 :1" {
+		t.Errorf("TestPrepareXorgCommand: unexpected Xorg command: %v", xorg.Args)
+	}
+
+	c.rootlessXorg = true
+	xorg, restoreTTY, rootless = prepareXorgCommand(u, c, []string{"vt999", ":1"})
+	restoreTTY()
+	if xorg.SysProcAttr != nil || rootless {
+		t.Error("TestPrepareXorgCommand: Xorg should fall back to root, if TTY could not be handed over")
+	}
+}
+
+func TestGetXorgLogPath(t *testing.T) {
+	u := &sysuser{homedir: "/home/emptty"}
+	if getXorgLogPath(u, 1, false) != "/var/log/Xorg.1.log" {
+		t.Error("TestGetXorgLogPath: Xorg started as root should log into /var/log")
+	}
+	if getXorgLogPath(u, 1, true) != "/home/emptty/.local/share/xorg/Xorg.1.log" {
+		t.Error("TestGetXorgLogPath: rootless Xorg should log into user's home directory")
+	}
+}
+
+func TestHandOverTTY(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("TestHandOverTTY: changing ownership requires root")
+	}
+
+	f, err := ioutil.TempFile("", "emptty-tty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	u := &sysuser{uid: 3000, gid: 2000}
+	restoreTTY, err := handOverTTY(u, f.Name())
+	if err != nil {
+		t.Fatalf("TestHandOverTTY: %v", err)
+	}
+
+	info, _ := os.Stat(f.Name())
+	if stat := info.Sys().(*syscall.Stat_t); stat.Uid != 3000 || stat.Gid != 2000 {
+		t.Errorf("TestHandOverTTY: TTY was not handed over: %d:%d", stat.Uid, stat.Gid)
+	}
+
+	restoreTTY()
+	info, _ = os.Stat(f.Name())
+	if stat := info.Sys().(*syscall.Stat_t); int(stat.Uid) != os.Getuid() || int(stat.Gid) != os.Getgid() {
+		t.Errorf("TestHandOverTTY: TTY ownership was not restored: %d:%d", stat.Uid, stat.Gid)
+	}
+
+	if _, err := handOverTTY(u, f.Name()+"-missing"); err == nil {
+		t.Error("TestHandOverTTY: missing TTY should return error")
+	}
+}
+
+func TestPrepareXorgLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emptty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	u := &sysuser{uid: os.Getuid(), gid: os.Getgid(), homedir: dir}
+	path := prepareXorgLog(u, 3)
+	if path != dir+"/.local/share/emptty/Xorg.3.log" || !fileExists(dir+"/.local/share/emptty") {
+		t.Fatalf("TestPrepareXorgLog: unexpected path of Xorg log %s", path)
+	}
+
+	ioutil.WriteFile(path, []byte("previous"), 0644)
+	prepareXorgLog(u, 3)
+	if fileExists(path) || !fileExists(path+".old") {
+		t.Error("TestPrepareXorgLog: previous Xorg log was not rotated")
+	}
+}
+
+func TestOpenXorgLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emptty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	u := &sysuser{uid: os.Getuid(), gid: os.Getgid(), homedir: dir}
+	f := openXorgLog(u, 4)
+	if f == nil {
+		t.Fatal("TestOpenXorgLog: Xorg log was not opened")
+	}
+	f.Close()
+	if !fileExists(dir + "/.local/share/emptty/Xorg.4.log") {
+		t.Error("TestOpenXorgLog: Xorg log was not created")
+	}
+}
+
+func TestOpenSessionLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emptty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	u := &sysuser{uid: os.Getuid(), gid: os.Getgid(), homedir: dir}
+	if openSessionLog(u, Disabled) != nil {
+		t.Error("TestOpenSessionLog: disabled session log should not be opened")
+	}
+
+	path := dir + "/.local/share/emptty/session.log"
+	f := openSessionLog(u, Default)
+	if f == nil {
+		t.Fatal("TestOpenSessionLog: session log was not opened")
+	}
+	f.WriteString("first\n")
+	f.Close()
+
+	f = openSessionLog(u, Appending)
+	f.WriteString("second\n")
+	f.Close()
+
+	data, _ := ioutil.ReadFile(path)
+	if string(data) != "first\nsecond\n" {
+		t.Errorf("TestOpenSessionLog: unexpected appended session log '%s'", string(data))
+	}
+
+	f = openSessionLog(u, Default)
+	f.Close()
+	data, _ = ioutil.ReadFile(path + ".old")
+	current, _ := ioutil.ReadFile(path)
+	if string(data) != "first\nsecond\n" || len(current) != 0 {
+		t.Error("TestOpenSessionLog: previous session log was not rotated")
+	}
+}
+
+func TestStartSession(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("TestStartSession: starting session as user requires root")
+	}
+
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+
+	dir, err := ioutil.TempDir("", "emptty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(dir+"/home/.config", 0700)
+	ioutil.WriteFile(dir+"/home/.config/emptty", []byte("ENVIRONMENT=console\nEXEC=touch "+dir+"/started\n"), 0600)
+	ioutil.WriteFile(dir+"/stop.sh", []byte("#!/bin/sh\ntouch "+dir+"/stopped\n"), 0700)
+	os.Mkdir(dir+"/runtime", 0700)
+	ioutil.WriteFile(dir+"/file", nil, 0600)
+
+	releasedId := make(chan string, 2)
+	server := startFakeDbusServer(t, func(member string, msg *dbus.Message) *dbus.Message {
+		switch member {
+		case "CreateSession":
+			fds := make([]int, 2)
+			syscall.Pipe(fds)
+			syscall.Close(fds[1])
+			return newFakeDbusReply("c1", dbus.ObjectPath("/org/freedesktop/login1/session/c1"), "", dbus.UnixFD(fds[0]), uint32(0), "seat0", uint32(7), false)
+		case "ReleaseSession":
+			releasedId <- msg.Body[0].(string)
+			return newFakeDbusReply()
+		}
+		return newFakeDbusError("org.freedesktop.DBus.Error.UnknownMethod")
+	})
+	defer server.stop()
+
+	previousAddress := os.Getenv(envDbusSystemBusAddress)
+	os.Setenv(envDbusSystemBusAddress, server.address)
+	defer os.Setenv(envDbusSystemBusAddress, previousAddress)
+
+	current, _ := user.Current()
+	conf := &config{tty: 7, logindSession: true, displayStopScript: dir + "/stop.sh"}
+
+	// finished session
+	usr := getSysuser(current)
+	usr.homedir = dir + "/home"
+	auth := &fakeAuth{env: map[string]string{envXdgRuntimeDir: dir + "/runtime"}, opened: true}
+	readOutput(func() {
+		startSession(usr, conf, auth)
+	})
+
+	if !fileExists(dir + "/started") {
+		t.Error("TestStartSession: session was not started")
+	}
+	if !auth.closed || <-releasedId != "c1" || !fileExists(dir+"/stopped") {
+		t.Error("TestStartSession: finished session was not cleaned up")
+	}
+
+	// session aborted after logind session was registered
+	os.Remove(dir + "/stopped")
+	loginRunning = true
+	defer func() {
+		loginRunning = false
+	}()
+
+	usr = getSysuser(current)
+	usr.homedir = dir + "/home"
+	auth = &fakeAuth{env: map[string]string{envXdgRuntimeDir: dir + "/file/runtime"}, opened: true}
+	var aborted interface{}
+	readOutput(func() {
+		defer func() {
+			aborted = recover()
+		}()
+		startSession(usr, conf, auth)
+	})
+
+	if _, ok := aborted.(*abortedLogin); !ok {
+		t.Fatalf("TestStartSession: session should be aborted, got %v", aborted)
+	}
+	if !auth.closed || <-releasedId != "c1" {
+		t.Error("TestStartSession: aborted session was not cleaned up")
+	}
+	if fileExists(dir + "/stopped") {
+		t.Error("TestStartSession: stop script should not run, if start script did not run")
+	}
+}
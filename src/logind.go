@@ -0,0 +1,130 @@
+package src
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	logindName  = "org.freedesktop.login1"
+	logindPath  = "/org/freedesktop/login1"
+	logindIface = "org.freedesktop.login1.Manager"
+
+	envDbusSystemBusAddress  = "DBUS_SYSTEM_BUS_ADDRESS"
+	defaultDbusSystemAddress = "unix:path=/run/dbus/system_bus_socket"
+)
+
+// logindProperty defines additional property of session created in logind.
+type logindProperty struct {
+	Name  string
+	Value dbus.Variant
+}
+
+// logindSession defines session registered in logind.
+type logindSession struct {
+	address     string
+	id          string
+	runtimePath string
+	seat        string
+	vtnr        uint32
+	fifo        *os.File
+}
+
+// Registers session in logind, if it is enabled and PAM did not register the session already.
+// Session ID, seat and runtime path are exported into user's environment.
+// If registration fails, session continues without logind and nil is returned.
+func openLogindSession(usr *sysuser, d *desktop, conf *config) *logindSession {
+	if !conf.logindSession || usr.getenv(envXdgSessionId) != "" {
+		return nil
+	}
+
+	session, err := createLogindSession(getDbusSystemAddress(), usr, d, conf)
+	if err != nil {
+		log.Print("Could not register logind session: ", err)
+		return nil
+	}
+
+	usr.setenv(envXdgSessionId, session.id)
+	if session.runtimePath != "" {
+		usr.setenv(envXdgRuntimeDir, session.runtimePath)
+	}
+	if session.seat != "" {
+		usr.setenv(envXdgSeat, session.seat)
+	}
+	if session.vtnr > 0 {
+		usr.setenv(envXdgVtnr, strconv.Itoa(int(session.vtnr)))
+	}
+	log.Print("Registered logind session " + session.id)
+	return session
+}
+
+// Calls CreateSession of logind for current process on bus defined by address.
+func createLogindSession(address string, usr *sysuser, d *desktop, conf *config) (*logindSession, error) {
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	tty := ""
+	if conf.tty > 0 {
+		tty = "tty" + conf.strTTY()
+	}
+
+	session := &logindSession{address: address}
+	var objectPath dbus.ObjectPath
+	var fifo dbus.UnixFD
+	var uid uint32
+	var existing bool
+	err = conn.Object(logindName, logindPath).Call(logindIface+".CreateSession", 0,
+		usr.uidu32(), uint32(os.Getpid()), "emptty", getLogindSessionType(d), "user", d.name, "seat0", uint32(conf.tty),
+		tty, "", false, "", "", []logindProperty{}).Store(&session.id, &objectPath, &session.runtimePath, &fifo, &uid, &session.seat, &session.vtnr, &existing)
+	if err != nil {
+		return nil, err
+	}
+
+	// logind keeps session open, until its fifo is closed
+	session.fifo = os.NewFile(uintptr(fifo), "logind-session-fifo")
+	return session, nil
+}
+
+// Releases session in logind and closes its fifo.
+func (s *logindSession) release() {
+	if s == nil {
+		return
+	}
+
+	conn, err := dbus.Connect(s.address)
+	if err == nil {
+		err = conn.Object(logindName, logindPath).Call(logindIface+".ReleaseSession", 0, s.id).Err
+		conn.Close()
+	}
+	if err != nil {
+		log.Print("Could not release logind session: ", err)
+	}
+
+	s.fifo.Close()
+	log.Print("Released logind session " + s.id)
+}
+
+// Gets address of D-Bus system bus.
+func getDbusSystemAddress() string {
+	if address := os.Getenv(envDbusSystemBusAddress); address != "" {
+		return address
+	}
+	return defaultDbusSystemAddress
+}
+
+// Gets logind session type according to desktop environment.
+func getLogindSessionType(d *desktop) string {
+	switch d.env {
+	case Wayland:
+		return "wayland"
+	case Console:
+		return "tty"
+	}
+	return "x11"
+}
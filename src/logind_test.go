@@ -0,0 +1,206 @@
+package src
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeDbusServer simulates D-Bus daemon, that passes method calls to handler.
+type fakeDbusServer struct {
+	dir      string
+	address  string
+	listener *net.UnixListener
+	handler  func(member string, msg *dbus.Message) *dbus.Message
+}
+
+// Starts fake D-Bus daemon listening on socket in temporary directory.
+func startFakeDbusServer(t *testing.T, handler func(member string, msg *dbus.Message) *dbus.Message) *fakeDbusServer {
+	dir, err := ioutil.TempDir("", "emptty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := dir + "/bus"
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	s := &fakeDbusServer{dir: dir, address: "unix:path=" + path, listener: listener, handler: handler}
+	go s.serve()
+	return s
+}
+
+// Stops fake D-Bus daemon.
+func (s *fakeDbusServer) stop() {
+	s.listener.Close()
+	os.RemoveAll(s.dir)
+}
+
+// Accepts connections of fake D-Bus daemon.
+func (s *fakeDbusServer) serve() {
+	for {
+		conn, err := s.listener.AcceptUnix()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// Authenticates client and replies its method calls.
+func (s *fakeDbusServer) handle(conn *net.UnixConn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	if _, err := r.ReadByte(); err != nil {
+		return
+	}
+	for authenticated := false; !authenticated; {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSuffix(line, "\r\n")
+		switch {
+		case line == "AUTH":
+			conn.Write([]byte("REJECTED EXTERNAL\r\n"))
+		case strings.HasPrefix(line, "AUTH EXTERNAL"):
+			conn.Write([]byte("OK 0123456789abcdef0123456789abcdef\r\n"))
+		case line == "NEGOTIATE_UNIX_FD":
+			conn.Write([]byte("AGREE_UNIX_FD\r\n"))
+		case line == "BEGIN":
+			authenticated = true
+		default:
+			conn.Write([]byte("ERROR\r\n"))
+		}
+	}
+
+	for serial := uint32(1); ; serial++ {
+		msg, err := dbus.DecodeMessage(r)
+		if err != nil {
+			return
+		}
+
+		member, _ := msg.Headers[dbus.FieldMember].Value().(string)
+		var reply *dbus.Message
+		if member == "Hello" {
+			reply = newFakeDbusReply(":1.1")
+		} else {
+			reply = s.handler(member, msg)
+		}
+		reply.Headers[dbus.FieldReplySerial] = dbus.MakeVariant(msg.Serial())
+		if count, _ := reply.CountFds(); count > 0 {
+			reply.Headers[dbus.FieldUnixFDs] = dbus.MakeVariant(uint32(count))
+		}
+
+		var buf bytes.Buffer
+		fds, err := reply.EncodeToWithFDs(&buf, binary.LittleEndian)
+		if err != nil {
+			return
+		}
+		data := buf.Bytes()
+		binary.LittleEndian.PutUint32(data[8:12], serial)
+
+		var oob []byte
+		if len(fds) > 0 {
+			oob = syscall.UnixRights(fds...)
+		}
+		conn.WriteMsgUnix(data, oob, nil)
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+	}
+}
+
+// Creates reply of fake D-Bus daemon with body.
+func newFakeDbusReply(body ...interface{}) *dbus.Message {
+	msg := &dbus.Message{Type: dbus.TypeMethodReply, Headers: make(map[dbus.HeaderField]dbus.Variant), Body: body}
+	if len(body) > 0 {
+		msg.Headers[dbus.FieldSignature] = dbus.MakeVariant(dbus.SignatureOf(body...))
+	}
+	return msg
+}
+
+// Creates error reply of fake D-Bus daemon.
+func newFakeDbusError(name string) *dbus.Message {
+	return &dbus.Message{Type: dbus.TypeError, Headers: map[dbus.HeaderField]dbus.Variant{dbus.FieldErrorName: dbus.MakeVariant(name)}}
+}
+
+func TestOpenLogindSession(t *testing.T) {
+	createArgs := make(chan []interface{}, 1)
+	releasedId := make(chan string, 1)
+
+	server := startFakeDbusServer(t, func(member string, msg *dbus.Message) *dbus.Message {
+		switch member {
+		case "CreateSession":
+			createArgs <- msg.Body
+			fds := make([]int, 2)
+			syscall.Pipe(fds)
+			syscall.Close(fds[1])
+			return newFakeDbusReply("c1", dbus.ObjectPath("/org/freedesktop/login1/session/c1"), "/run/user/3000", dbus.UnixFD(fds[0]), uint32(3000), "seat0", uint32(7), false)
+		case "ReleaseSession":
+			releasedId <- msg.Body[0].(string)
+			return newFakeDbusReply()
+		}
+		return newFakeDbusError("org.freedesktop.DBus.Error.UnknownMethod")
+	})
+	defer server.stop()
+
+	previousAddress := os.Getenv(envDbusSystemBusAddress)
+	os.Setenv(envDbusSystemBusAddress, server.address)
+	defer os.Setenv(envDbusSystemBusAddress, previousAddress)
+
+	u := &sysuser{uid: 3000, gid: 2000, env: make(map[string]string)}
+	d := &desktop{name: "Sway", env: Wayland}
+	c := &config{tty: 7}
+
+	if openLogindSession(u, d, c) != nil {
+		t.Error("TestOpenLogindSession: session should not be registered, if it is disabled")
+	}
+
+	c.logindSession = true
+	session := openLogindSession(u, d, c)
+	if session == nil {
+		t.Fatal("TestOpenLogindSession: session was not registered")
+	}
+
+	expectedArgs := []interface{}{uint32(3000), uint32(os.Getpid()), "emptty", "wayland", "user", "Sway", "seat0", uint32(7), "tty7", "", false, "", "", [][]interface{}{}}
+	if args := <-createArgs; !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("TestOpenLogindSession: unexpected arguments of CreateSession %v", args)
+	}
+	if u.getenv(envXdgSessionId) != "c1" || u.getenv(envXdgRuntimeDir) != "/run/user/3000" || u.getenv(envXdgSeat) != "seat0" || u.getenv(envXdgVtnr) != "7" {
+		t.Errorf("TestOpenLogindSession: unexpected environment %v", u.env)
+	}
+
+	session.release()
+	if <-releasedId != "c1" {
+		t.Error("TestOpenLogindSession: session was not released")
+	}
+
+	if openLogindSession(u, d, c) != nil {
+		t.Error("TestOpenLogindSession: session should not be registered, if it is already registered by PAM")
+	}
+
+	var nilSession *logindSession
+	nilSession.release()
+}
+
+func TestGetLogindSessionType(t *testing.T) {
+	for env, expected := range map[enEnvironment]string{Xorg: "x11", Wayland: "wayland", Console: "tty"} {
+		if result := getLogindSessionType(&desktop{env: env}); result != expected {
+			t.Errorf("TestGetLogindSessionType: unexpected session type '%s'", result)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package src
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+const (
+	strMenuHighlight = "\x1b[7m"
+	strMenuClearDown = "\x1b[J"
+)
+
+// enMenuKey defines keys, that are handled by selection menu.
+type enMenuKey int
+
+const (
+	// KeyChar represents printable character used for filtering
+	KeyChar enMenuKey = iota + 1
+
+	// KeyUp represents arrow up
+	KeyUp
+
+	// KeyDown represents arrow down
+	KeyDown
+
+	// KeyEnter represents confirmation of selection
+	KeyEnter
+
+	// KeyBackspace represents removal of last filtered character
+	KeyBackspace
+
+	// KeyEscape represents reset of filter
+	KeyEscape
+)
+
+// menuKey defines pressed key with its character, if it is printable.
+type menuKey struct {
+	key  enMenuKey
+	char byte
+}
+
+// selectionMenu defines state of interactive selection of desktops.
+type selectionMenu struct {
+	desktops []*desktop
+	filtered []int
+	filter   string
+	cursor   int
+	lines    int
+}
+
+// Creates selection menu with preselected last used desktop.
+func newSelectionMenu(desktops []*desktop, lastDesktop int) *selectionMenu {
+	m := &selectionMenu{desktops: desktops}
+	m.applyFilter()
+	if lastDesktop >= 0 && lastDesktop < len(m.filtered) {
+		m.cursor = lastDesktop
+	}
+	return m
+}
+
+// Lets user select desktop with arrow keys, typed characters filter desktops by name.
+// It returns index of selected desktop. If selection is interrupted by signal, terminal is restored and errInterrupted is returned.
+func selectDesktopFromMenu(desktops []*desktop, lastDesktop int, conf *config) (int, error) {
+	fd := os.Stdin.Fd()
+	c := notifyTerminalSignals()
+	defer signal.Stop(c)
+
+	original, err := makeRawTerminal(fd)
+	if err != nil {
+		return -1, err
+	}
+	defer setTermios(fd, original)
+
+	colors := getColorsEscape("", "")
+	if conf.daemonMode {
+		colors = getColorsEscape(conf.fgColor, conf.bgColor)
+	}
+
+	m := newSelectionMenu(desktops, lastDesktop)
+	buf := make([]byte, 32)
+
+	fmt.Println()
+	for {
+		m.render(os.Stdout, colors)
+
+		n, err := readRawTerminal(fd, buf, c)
+		if err != nil {
+			fmt.Println()
+			return -1, err
+		}
+		for _, k := range parseMenuKeys(buf[:n]) {
+			if m.handleKey(k) {
+				fmt.Println()
+				return m.selected(), nil
+			}
+		}
+	}
+}
+
+// Filters desktops by their names, cursor stays on previously selected desktop, if it still matches.
+func (m *selectionMenu) applyFilter() {
+	previous := m.selected()
+	filter := strings.ToLower(m.filter)
+
+	m.filtered = nil
+	m.cursor = 0
+	for i, d := range m.desktops {
+		if filter == "" || strings.Contains(strings.ToLower(d.getDisplayName()), filter) {
+			if i == previous {
+				m.cursor = len(m.filtered)
+			}
+			m.filtered = append(m.filtered, i)
+		}
+	}
+}
+
+// Gets index of currently selected desktop, or -1 if no desktop matches the filter.
+func (m *selectionMenu) selected() int {
+	if m.cursor >= 0 && m.cursor < len(m.filtered) {
+		return m.filtered[m.cursor]
+	}
+	return -1
+}
+
+// Handles pressed key and returns true, if selection was confirmed.
+func (m *selectionMenu) handleKey(k menuKey) bool {
+	switch k.key {
+	case KeyUp:
+		if len(m.filtered) > 0 {
+			m.cursor = (m.cursor - 1 + len(m.filtered)) % len(m.filtered)
+		}
+	case KeyDown:
+		if len(m.filtered) > 0 {
+			m.cursor = (m.cursor + 1) % len(m.filtered)
+		}
+	case KeyEnter:
+		return m.selected() >= 0
+	case KeyBackspace:
+		if m.filter != "" {
+			m.filter = m.filter[:len(m.filter)-1]
+			m.applyFilter()
+		}
+	case KeyEscape:
+		m.filter = ""
+		m.applyFilter()
+	case KeyChar:
+		m.filter += string(k.char)
+		m.applyFilter()
+	}
+	return false
+}
+
+// Renders menu over previously rendered one, colors are used to restore defined colors after highlighted row.
+func (m *selectionMenu) render(w io.Writer, colors string) {
+	var sb strings.Builder
+
+	if m.lines > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dA", m.lines)
+	}
+	sb.WriteString("\r" + strMenuClearDown)
+
+	lines := 0
+	for i, id := range m.filtered {
+		if i == m.cursor {
+			sb.WriteString("> " + strMenuHighlight + m.desktops[id].getDisplayName() + colors + "\n")
+		} else {
+			sb.WriteString("  " + m.desktops[id].getDisplayName() + "\n")
+		}
+		lines++
+	}
+	if len(m.filtered) == 0 {
+		sb.WriteString("  No session matches the filter\n")
+		lines++
+	}
+	sb.WriteString("Select (type to filter): " + m.filter)
+
+	m.lines = lines
+	w.Write([]byte(sb.String()))
+}
+
+// Parses input read from terminal into keys handled by selection menu.
+func parseMenuKeys(input []byte) []menuKey {
+	var result []menuKey
+
+	for i := 0; i < len(input); i++ {
+		b := input[i]
+		switch {
+		case b == '\r' || b == '\n':
+			result = append(result, menuKey{key: KeyEnter})
+		case b == 0x7f || b == 0x08:
+			result = append(result, menuKey{key: KeyBackspace})
+		case b == 0x1b:
+			if i+2 < len(input) && (input[i+1] == '[' || input[i+1] == 'O') {
+				// skip parameters of escape sequence up to its final byte
+				j := i + 2
+				for j < len(input)-1 && (input[j] < 0x40 || input[j] > 0x7e) {
+					j++
+				}
+				switch input[j] {
+				case 'A':
+					result = append(result, menuKey{key: KeyUp})
+				case 'B':
+					result = append(result, menuKey{key: KeyDown})
+				}
+				i = j
+			} else {
+				result = append(result, menuKey{key: KeyEscape})
+			}
+		case b >= 0x20 && b < 0x7f:
+			result = append(result, menuKey{key: KeyChar, char: b})
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,102 @@
+package src
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func getMenuTestingDesktops() []*desktop {
+	return []*desktop{{name: "i3"}, {name: "Openbox"}, {name: "Sway"}, {name: "Plasma (Wayland)"}, {name: "Plasma"}}
+}
+
+func TestParseMenuKeys(t *testing.T) {
+	keys := parseMenuKeys([]byte("\x1b[A\x1b[Bab\x7f\r\x1bOA\x1b[5~\x1b"))
+
+	expected := []menuKey{{key: KeyUp}, {key: KeyDown}, {key: KeyChar, char: 'a'}, {key: KeyChar, char: 'b'}, {key: KeyBackspace}, {key: KeyEnter}, {key: KeyUp}, {key: KeyEscape}}
+	if len(keys) != len(expected) {
+		t.Fatalf("TestParseMenuKeys: unexpected count of keys: %v", keys)
+	}
+	for i, k := range keys {
+		if k != expected[i] {
+			t.Errorf("TestParseMenuKeys: unexpected key on position %d: %v", i, k)
+		}
+	}
+}
+
+func TestNewSelectionMenu(t *testing.T) {
+	m := newSelectionMenu(getMenuTestingDesktops(), 3)
+	if m.selected() != 3 {
+		t.Error("TestNewSelectionMenu: last desktop is not preselected")
+	}
+
+	m = newSelectionMenu(getMenuTestingDesktops(), 10)
+	if m.selected() != 0 {
+		t.Error("TestNewSelectionMenu: first desktop should be selected for unknown last desktop")
+	}
+}
+
+func TestSelectionMenuMoving(t *testing.T) {
+	m := newSelectionMenu(getMenuTestingDesktops(), 0)
+
+	m.handleKey(menuKey{key: KeyUp})
+	if m.selected() != 4 {
+		t.Error("TestSelectionMenuMoving: arrow up should move to the last desktop")
+	}
+
+	m.handleKey(menuKey{key: KeyDown})
+	m.handleKey(menuKey{key: KeyDown})
+	if m.selected() != 1 {
+		t.Error("TestSelectionMenuMoving: arrow down should move to the next desktop")
+	}
+
+	if !m.handleKey(menuKey{key: KeyEnter}) {
+		t.Error("TestSelectionMenuMoving: enter should confirm selection")
+	}
+}
+
+func TestSelectionMenuFilter(t *testing.T) {
+	m := newSelectionMenu(getMenuTestingDesktops(), 4)
+
+	m.handleKey(menuKey{key: KeyChar, char: 'p'})
+	m.handleKey(menuKey{key: KeyChar, char: 'L'})
+	if len(m.filtered) != 2 || m.selected() != 4 {
+		t.Errorf("TestSelectionMenuFilter: unexpected filtered desktops %v, selected %d", m.filtered, m.selected())
+	}
+
+	m.handleKey(menuKey{key: KeyChar, char: 'x'})
+	if m.selected() != -1 || m.handleKey(menuKey{key: KeyEnter}) {
+		t.Error("TestSelectionMenuFilter: no desktop should be selectable")
+	}
+
+	m.handleKey(menuKey{key: KeyBackspace})
+	m.handleKey(menuKey{key: KeyDown})
+	if m.selected() != 4 {
+		t.Errorf("TestSelectionMenuFilter: unexpected selected desktop %d", m.selected())
+	}
+
+	m.handleKey(menuKey{key: KeyEscape})
+	if m.filter != "" || len(m.filtered) != 5 || m.selected() != 4 {
+		t.Error("TestSelectionMenuFilter: filter was not reset")
+	}
+}
+
+func TestSelectionMenuRender(t *testing.T) {
+	m := newSelectionMenu(getMenuTestingDesktops(), 2)
+	buf := new(bytes.Buffer)
+
+	m.render(buf, "\x1b[0;31m")
+	if !strings.Contains(buf.String(), "> "+strMenuHighlight+"Sway\x1b[0;31m\n") {
+		t.Errorf("TestSelectionMenuRender: selected desktop is not highlighted: '%s'", buf.String())
+	}
+	if m.lines != 5 {
+		t.Errorf("TestSelectionMenuRender: unexpected count of rendered lines: %d", m.lines)
+	}
+
+	buf.Reset()
+	m.handleKey(menuKey{key: KeyChar, char: 'z'})
+	m.render(buf, "")
+	if !strings.HasPrefix(buf.String(), "\x1b[5A") || m.lines != 1 {
+		t.Errorf("TestSelectionMenuRender: previous menu was not overwritten: '%s'", buf.String())
+	}
+}
@@ -73,6 +73,11 @@ func revertColorEscaping(value string) string {
 
 // Sets defined colors.
 func setColors(fg string, bg string) {
+	fmt.Print(getColorsEscape(fg, bg) + "\n")
+}
+
+// Gets escape sequence of defined colors.
+func getColorsEscape(fg string, bg string) string {
 	color := ""
 
 	if fg != "" {
@@ -88,7 +93,7 @@ func setColors(fg string, bg string) {
 	if fg == "" && bg == "" {
 		color = "0"
 	}
-	fmt.Print("\x1b[0;" + color + "m\n")
+	return "\x1b[0;" + color + "m"
 }
 
 // Resets colors to default.
@@ -1,54 +1,84 @@
 package src
 
 import (
-	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
-	"syscall"
+	"unicode/utf8"
 )
 
-// Reads password without echoing it
-func readPassword() (string, error) {
-	c := make(chan os.Signal, 10)
-
-	fd := []uintptr{os.Stdin.Fd(), os.Stdout.Fd(), os.Stderr.Fd()}
+const (
+	strPasswordMask      = "*"
+	strPasswordMaskErase = "\b \b"
+)
 
-	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGKILL, syscall.SIGQUIT, syscall.SIGTERM)
+// passwordInput defines password being typed and if typed characters are echoed as mask.
+type passwordInput struct {
+	value []byte
+	mask  bool
+}
 
-	go handlePasswordInterrupt(c, fd)
+// Reads password without echoing it. If mask is enabled, each typed character is echoed as '*'.
+// If reading is interrupted by signal, terminal settings are restored and errInterrupted is returned.
+func readPassword(mask bool) (string, error) {
+	fd := os.Stdin.Fd()
+	c := notifyTerminalSignals()
+	defer signal.Stop(c)
 
-	err := setTerminalEcho(fd, false)
+	original, err := makeRawTerminal(fd)
 	if err != nil {
 		return "", err
 	}
-	defer signal.Stop(c)
-	defer setTerminalEcho(fd, true)
+	defer setTermios(fd, original)
 
-	input, err := bufio.NewReader(os.Stdin).ReadString('\n')
-	if err != nil {
-		return "", err
+	input := &passwordInput{mask: mask}
+	buf := make([]byte, 64)
+	for {
+		n, err := readRawTerminal(fd, buf, c)
+		if err != nil {
+			fmt.Println()
+			return "", err
+		}
+		if input.handle(buf[:n], os.Stdout) {
+			fmt.Println()
+			return string(input.value), nil
+		}
 	}
-	fmt.Println()
-	return input[:len(input)-1], nil
 }
 
-// Enables or disables echo depending on status
-func setTerminalEcho(fd []uintptr, status bool) error {
-	flag := ""
-	if !status {
-		flag = "-"
+// Handles bytes read from terminal, mask is written into w, if it is enabled.
+// It returns true, if input was confirmed by Enter.
+func (p *passwordInput) handle(input []byte, w io.Writer) bool {
+	for _, b := range input {
+		switch b {
+		case '\r', '\n':
+			return true
+		case 0x7f, 0x08:
+			if len(p.value) > 0 {
+				_, size := utf8.DecodeLastRune(p.value)
+				p.value = p.value[:len(p.value)-size]
+				p.echo(w, strPasswordMaskErase)
+			}
+		case 0x15:
+			for i := utf8.RuneCount(p.value); i > 0; i-- {
+				p.echo(w, strPasswordMaskErase)
+			}
+			p.value = nil
+		default:
+			p.value = append(p.value, b)
+			// continuation bytes of UTF-8 character are not masked again
+			if b&0xC0 != 0x80 {
+				p.echo(w, strPasswordMask)
+			}
+		}
 	}
-	pid, err := syscall.ForkExec("/bin/stty", []string{"stty", flag + "echo"}, &syscall.ProcAttr{Dir: "", Files: fd})
-	if err == nil {
-		syscall.Wait4(pid, nil, 0, nil)
-	}
-	return err
+	return false
 }
 
-// Enables echo on interruption and provide interrupt.
-func handlePasswordInterrupt(c chan os.Signal, fd []uintptr) {
-	<-c
-	setTerminalEcho(fd, true)
-	os.Exit(-1)
+// Writes value into w, if mask is enabled.
+func (p *passwordInput) echo(w io.Writer, value string) {
+	if p.mask {
+		w.Write([]byte(value))
+	}
 }
@@ -0,0 +1,31 @@
+package src
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPasswordInput(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := &passwordInput{mask: true}
+
+	if p.handle([]byte("ab\x7fcč"), buf) {
+		t.Error("TestPasswordInput: input should not be confirmed without Enter")
+	}
+	if !p.handle([]byte("\x7fd\rignored"), buf) {
+		t.Error("TestPasswordInput: input should be confirmed by Enter")
+	}
+	if string(p.value) != "acd" {
+		t.Errorf("TestPasswordInput: unexpected password '%s'", p.value)
+	}
+	if buf.String() != "**\b \b**\b \b*" {
+		t.Errorf("TestPasswordInput: unexpected mask '%s'", buf.String())
+	}
+
+	buf.Reset()
+	p = &passwordInput{}
+	p.handle([]byte("secret\x15pass"), buf)
+	if string(p.value) != "pass" || buf.Len() != 0 {
+		t.Errorf("TestPasswordInput: unexpected password '%s' or echo '%s'", p.value, buf.String())
+	}
+}
@@ -0,0 +1,109 @@
+package src
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+)
+
+const (
+	pathRuntimeDir        = "/run/user/"
+	pathSessionsDir       = "/run/emptty/sessions/"
+	fileRuntimeDirCreated = "runtime-dir-created"
+)
+
+// Gets XDG_RUNTIME_DIR of user. Only value defined by PAM or logind is accepted, otherwise default path is used.
+// It has to be called before environment files and variables of user are applied.
+func getRuntimeDir(usr *sysuser) string {
+	if runtimeDir := usr.getenv(envXdgRuntimeDir); runtimeDir != "" {
+		return runtimeDir
+	}
+	return pathRuntimeDir + usr.strUid()
+}
+
+// Creates XDG_RUNTIME_DIR, if it does not exist yet, and registers current session of user.
+func prepareRuntimeDir(usr *sysuser) {
+	runtimeDir := usr.runtimeDir
+
+	created := false
+	if !fileExists(runtimeDir) {
+		err := os.MkdirAll(runtimeDir, 0700)
+		handleErr(err)
+
+		// Set owner of XDG folder
+		os.Chown(runtimeDir, usr.uid, usr.gid)
+		created = true
+
+		log.Print("Created XDG folder")
+	} else {
+		log.Print("XDG folder already exists, no need to create")
+	}
+
+	if err := registerSession(pathSessionsDir, usr.uid, created); err != nil {
+		log.Print(err)
+	}
+}
+
+// Unregisters current session of user and removes XDG_RUNTIME_DIR,
+// if it was created by emptty and no other session of user is active.
+func releaseRuntimeDir(usr *sysuser) {
+	if usr.runtimeDir == "" {
+		return
+	}
+	if unregisterSession(pathSessionsDir, usr.uid) {
+		runtimeDir := usr.runtimeDir
+		if err := os.RemoveAll(runtimeDir); err != nil {
+			log.Print(err)
+			return
+		}
+		log.Print("Removed XDG folder")
+	}
+}
+
+// Registers session of current process for uid. If created is true, runtime dir is marked as created by emptty.
+func registerSession(sessionsDir string, uid int, created bool) error {
+	userDir := sessionsDir + strconv.Itoa(uid) + "/"
+	if err := os.MkdirAll(userDir, 0700); err != nil {
+		return err
+	}
+
+	if created {
+		if err := ioutil.WriteFile(userDir+fileRuntimeDirCreated, nil, 0600); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(userDir+strconv.Itoa(os.Getpid()), nil, 0600)
+}
+
+// Unregisters session of current process for uid, sessions of finished processes are removed too.
+// It returns true, if it was the last session of uid and runtime dir was created by emptty.
+func unregisterSession(sessionsDir string, uid int) bool {
+	userDir := sessionsDir + strconv.Itoa(uid) + "/"
+	os.Remove(userDir + strconv.Itoa(os.Getpid()))
+
+	infos, err := ioutil.ReadDir(userDir)
+	if err != nil {
+		return false
+	}
+
+	active := 0
+	for _, info := range infos {
+		pid, err := strconv.Atoi(info.Name())
+		if err != nil {
+			continue
+		}
+		if isProcessAlive(pid) {
+			active++
+		} else {
+			os.Remove(userDir + info.Name())
+		}
+	}
+	if active > 0 {
+		return false
+	}
+
+	created := fileExists(userDir + fileRuntimeDirCreated)
+	os.RemoveAll(userDir)
+	return created
+}
@@ -0,0 +1,61 @@
+package src
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+)
+
+func TestRegisterSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emptty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sessionsDir := dir + "/"
+
+	if err := registerSession(sessionsDir, 3000, true); err != nil {
+		t.Fatalf("TestRegisterSession: %v", err)
+	}
+	if !fileExists(sessionsDir+"3000/"+strconv.Itoa(os.Getpid())) || !fileExists(sessionsDir+"3000/"+fileRuntimeDirCreated) {
+		t.Fatal("TestRegisterSession: session was not registered")
+	}
+
+	// session of running process keeps runtime dir
+	ioutil.WriteFile(sessionsDir+"3000/1", nil, 0600)
+	if unregisterSession(sessionsDir, 3000) {
+		t.Error("TestRegisterSession: runtime dir should be kept, while other session is active")
+	}
+
+	// session of finished process is removed
+	cmd := exec.Command("true")
+	cmd.Run()
+	os.Remove(sessionsDir + "3000/1")
+	ioutil.WriteFile(sessionsDir+"3000/"+strconv.Itoa(cmd.Process.Pid), nil, 0600)
+	registerSession(sessionsDir, 3000, false)
+	if !unregisterSession(sessionsDir, 3000) {
+		t.Error("TestRegisterSession: runtime dir created by emptty should be removed after last session")
+	}
+	if fileExists(sessionsDir + "3000") {
+		t.Error("TestRegisterSession: sessions of user were not cleaned up")
+	}
+
+	registerSession(sessionsDir, 3000, false)
+	if unregisterSession(sessionsDir, 3000) {
+		t.Error("TestRegisterSession: runtime dir not created by emptty should not be removed")
+	}
+}
+
+func TestGetRuntimeDir(t *testing.T) {
+	u := &sysuser{uid: 3000, env: map[string]string{}}
+	if getRuntimeDir(u) != "/run/user/3000" {
+		t.Errorf("TestGetRuntimeDir: default runtime dir was expected, got '%s'", getRuntimeDir(u))
+	}
+
+	u.setenv(envXdgRuntimeDir, "/run/user/3000-logind")
+	if getRuntimeDir(u) != "/run/user/3000-logind" {
+		t.Error("TestGetRuntimeDir: runtime dir defined by PAM or logind was expected")
+	}
+}
@@ -8,13 +8,14 @@ import (
 
 // Type sysuser defines default structure of user to easier passing of all values.
 type sysuser struct {
-	username string
-	homedir  string
-	uid      int
-	gid      int
-	gids     []int
-	gidsu32  []uint32
-	env      map[string]string
+	username   string
+	homedir    string
+	uid        int
+	gid        int
+	gids       []int
+	gidsu32    []uint32
+	env        map[string]string
+	runtimeDir string
 }
 
 // Loads all necessary info about user into sysuser struct.
@@ -0,0 +1,106 @@
+package src
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// terminalReadTimeout defines in tenths of second, how often are signals checked during reading from raw terminal.
+const terminalReadTimeout = 1
+
+// errInterrupted is returned, if reading from terminal was interrupted by signal.
+var errInterrupted = errors.New("Reading from terminal was interrupted by signal")
+
+// Gets current terminal settings of file descriptor.
+func getTermios(fd uintptr) (*syscall.Termios, error) {
+	termios := &syscall.Termios{}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlGetTermios, uintptr(unsafe.Pointer(termios))); errno != 0 {
+		return nil, errno
+	}
+	return termios, nil
+}
+
+// Sets terminal settings of file descriptor.
+func setTermios(fd uintptr, termios *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlSetTermios, uintptr(unsafe.Pointer(termios))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Checks, if file descriptor is a terminal.
+func isTerminal(fd uintptr) bool {
+	_, err := getTermios(fd)
+	return err == nil
+}
+
+// Disables canonical mode and echo of terminal, so each key press could be read immediately.
+// Read returns after timeout even without input, so signals could be checked by readRawTerminal.
+// It returns previous terminal settings, that should be used to restore the terminal.
+func makeRawTerminal(fd uintptr) (*syscall.Termios, error) {
+	original, err := getTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := *original
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO
+	raw.Cc[syscall.VMIN] = 0
+	raw.Cc[syscall.VTIME] = terminalReadTimeout
+
+	if err := setTermios(fd, &raw); err != nil {
+		return nil, err
+	}
+	return original, nil
+}
+
+// Starts catching of signals, that interrupt reading from raw terminal.
+func notifyTerminalSignals() chan os.Signal {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	return c
+}
+
+// Reads input from raw terminal until at least one byte is read.
+// If any signal is caught meanwhile, errInterrupted is returned, so the terminal could be restored.
+func readRawTerminal(fd uintptr, buf []byte, c chan os.Signal) (int, error) {
+	for {
+		select {
+		case <-c:
+			return 0, errInterrupted
+		default:
+		}
+
+		n, err := syscall.Read(int(fd), buf)
+		if err != nil && err != syscall.EINTR {
+			return 0, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// Gets foreground process group of terminal, it fails if terminal is not controlling terminal of current process.
+func getForegroundProcessGroup(fd uintptr) (int, error) {
+	var pgrp int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp))); errno != 0 {
+		return 0, errno
+	}
+	return int(pgrp), nil
+}
+
+// Sets foreground process group of terminal. SIGTTOU is ignored meanwhile, so it could be called from background process group.
+func setForegroundProcessGroup(fd uintptr, pgrp int) error {
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+
+	value := int32(pgrp)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&value))); errno != 0 {
+		return errno
+	}
+	return nil
+}
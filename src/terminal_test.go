@@ -0,0 +1,25 @@
+package src
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestReadRawTerminal(t *testing.T) {
+	r, w, _ := os.Pipe()
+	defer r.Close()
+	defer w.Close()
+
+	w.Write([]byte("a"))
+	buf := make([]byte, 8)
+	if n, err := readRawTerminal(r.Fd(), buf, nil); n != 1 || err != nil || buf[0] != 'a' {
+		t.Errorf("TestReadRawTerminal: unexpected result %d, %v", n, err)
+	}
+
+	c := make(chan os.Signal, 1)
+	c <- syscall.SIGTERM
+	if _, err := readRawTerminal(r.Fd(), buf, c); err != errInterrupted {
+		t.Errorf("TestReadRawTerminal: interruption was expected, got %v", err)
+	}
+}
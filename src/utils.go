@@ -8,8 +8,11 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"os/user"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 )
 
@@ -17,8 +20,21 @@ const (
 	pathLogFileNull      = "/dev/null"
 	pathLogFile          = "/var/log/emptty"
 	pathLogFileOldSuffix = ".old"
+	pathUserLogDir       = "/.local/share/emptty/"
+	pathSessionLog       = "session.log"
 )
 
+// loopMode defines, if emptty returns to login prompt after aborted or finished login.
+var loopMode bool
+
+// loginRunning defines, if errors abort current login by panic, so its deferred cleanup is done.
+var loginRunning bool
+
+// abortedLogin defines error, that aborted current login.
+type abortedLogin struct {
+	err error
+}
+
 // propertyFunc defines method to be invoked during readProperties method for each record.
 type propertyFunc func(key string, value string)
 
@@ -41,7 +57,7 @@ func readProperties(filePath string, method propertyFunc) error {
 			if strings.Index(value, "#") >= 0 {
 				value = value[:strings.Index(value, "#")]
 			}
-			key = strings.ToUpper(strings.TrimSpace(key))
+			key = normalizePropertyKey(strings.TrimSpace(key))
 			value = strings.TrimSpace(value)
 			method(key, value)
 		}
@@ -49,6 +65,14 @@ func readProperties(filePath string, method propertyFunc) error {
 	return scanner.Err()
 }
 
+// Converts key of property to upper case, names of environmental variables prefixed with "ENV_" keep their case.
+func normalizePropertyKey(key string) string {
+	if strings.HasPrefix(strings.ToUpper(key), desktopEnvPrefix) {
+		return desktopEnvPrefix + key[len(desktopEnvPrefix):]
+	}
+	return strings.ToUpper(key)
+}
+
 // Checks, if file on path exists.
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
@@ -69,16 +93,46 @@ func handleStrErr(err string) {
 }
 
 // If error is not nil, otherwise it prints error, waits for user input and then exits the program.
+// During login it aborts current login instead, so its cleanup is done before the program exits or loop continues.
 func handleErr(err error) {
 	if err != nil {
 		log.Print(err)
 		fmt.Printf("Error: %s\n", err)
 		fmt.Printf("\nPress Enter to continue...")
 		bufio.NewReader(os.Stdin).ReadString('\n')
+		if loginRunning {
+			panic(&abortedLogin{err})
+		}
 		os.Exit(1)
 	}
 }
 
+// Runs fn with filesystem credentials of user and restores current credentials afterwards.
+// Filesystem credentials are set per thread on Linux, so goroutine is locked to its thread meanwhile.
+func doAsUser(usr *sysuser, fn func()) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	currentUser, _ := user.Current()
+	previousUser := getSysuser(currentUser)
+
+	setFsUser(usr)
+	defer setFsUser(previousUser)
+
+	fn()
+}
+
+// Handles interruption of login by signal without waiting for user input, so emptty could be stopped.
+// During login it aborts current login and the loop is stopped.
+func handleInterruptErr(err error) {
+	log.Print(err)
+	atomic.StoreInt32(&interrupted, 1)
+	if loginRunning {
+		panic(&abortedLogin{err})
+	}
+	os.Exit(1)
+}
+
 // Initialize logger to file defined by pathLogFile.
 func initLogger(conf *config) {
 	logFilePath := pathLogFile
@@ -87,10 +141,7 @@ func initLogger(conf *config) {
 	}
 
 	if conf.logging == Default {
-		if fileExists(logFilePath) {
-			os.Remove(logFilePath + pathLogFileOldSuffix)
-			os.Rename(logFilePath, logFilePath+pathLogFileOldSuffix)
-		}
+		rotateLogFile(logFilePath)
 	} else if conf.logging == Disabled {
 		logFilePath = pathLogFileNull
 	}
@@ -101,6 +152,14 @@ func initLogger(conf *config) {
 	}
 }
 
+// Moves existing log file into backup with ".old" suffix.
+func rotateLogFile(path string) {
+	if fileExists(path) {
+		os.Remove(path + pathLogFileOldSuffix)
+		os.Rename(path, path+pathLogFileOldSuffix)
+	}
+}
+
 // Sanitize value.
 func sanitizeValue(value string, defaultValue string) string {
 	if value == "" {
@@ -187,6 +246,15 @@ func parseBool(strBool string, defaultValue string) bool {
 	return val
 }
 
+// Parse integer values, invalid value falls back to default value.
+func parseInt(strInt string, defaultValue string) int {
+	val, err := strconv.ParseInt(sanitizeValue(strInt, defaultValue), 10, 32)
+	if err != nil {
+		val, _ = strconv.ParseInt(defaultValue, 10, 32)
+	}
+	return int(val)
+}
+
 // Runs wimple command and returns its output as string
 func runSimpleCmd(cmd []string) string {
 	output, err := exec.Command(cmd[0], cmd[1:]...).Output()
@@ -6,11 +6,19 @@ import (
 	"syscall"
 )
 
-// Sets fsuid, fsgid and fsgroups according sysuser
+// Sets effective uid and gid according sysuser. Effective root is regained first, so it could be changed back later.
 func setFsUser(usr *sysuser) {
-	err := syscall.Setuid(usr.uid)
+	err := syscall.Seteuid(0)
 	handleErr(err)
 
-	err = syscall.Setgid(usr.gid)
+	err = syscall.Setegid(usr.gid)
+	handleErr(err)
+
+	err = syscall.Seteuid(usr.uid)
 	handleErr(err)
 }
+
+const (
+	ioctlGetTermios = syscall.TIOCGETA
+	ioctlSetTermios = syscall.TIOCSETA
+)
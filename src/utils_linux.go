@@ -15,3 +15,8 @@ func setFsUser(usr *sysuser) {
 	err = syscall.Setfsgid(usr.gid)
 	handleErr(err)
 }
+
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
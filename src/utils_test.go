@@ -1,6 +1,7 @@
 package src
 
 import (
+	"errors"
 	"io/ioutil"
 	"log"
 	"os"
@@ -171,3 +172,61 @@ func TestCmdAsUser(t *testing.T) {
 		t.Error("TestCmdAsUser: unexpected UID")
 	}
 }
+
+func TestHandleErrDuringLogin(t *testing.T) {
+	loginRunning = true
+	defer func() {
+		loginRunning = false
+	}()
+
+	var aborted interface{}
+	readOutput(func() {
+		defer func() {
+			aborted = recover()
+		}()
+		handleErr(errors.New("testing error"))
+	})
+
+	if r, ok := aborted.(*abortedLogin); !ok || r.err.Error() != "testing error" {
+		t.Error("TestHandleErrDuringLogin: login should be aborted instead of exiting")
+	}
+
+	readOutput(func() {
+		handleErr(nil)
+	})
+}
+
+func TestRotateLogFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emptty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := dir + "/test.log"
+	rotateLogFile(path)
+	if fileExists(path + ".old") {
+		t.Error("TestRotateLogFile: missing log should not be rotated")
+	}
+
+	ioutil.WriteFile(path, []byte("current"), 0644)
+	ioutil.WriteFile(path+".old", []byte("old"), 0644)
+	rotateLogFile(path)
+
+	data, _ := ioutil.ReadFile(path + ".old")
+	if fileExists(path) || string(data) != "current" {
+		t.Error("TestRotateLogFile: log was not rotated")
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	if parseInt(" 5 ", "3") != 5 {
+		t.Error("TestParseInt: valid value was not parsed")
+	}
+	if parseInt("", "3") != 3 {
+		t.Error("TestParseInt: empty value should fall back to default value")
+	}
+	if parseInt("three", "3") != 3 {
+		t.Error("TestParseInt: invalid value should fall back to default value")
+	}
+}
@@ -0,0 +1,49 @@
+// +build dragonfly freebsd netbsd openbsd
+
+package src
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// vtState is not used on BSD.
+type vtState struct{}
+
+// Automatic allocation of VT is not supported on BSD.
+func getFreeTTY() (int, error) {
+	return 0, errors.New("Automatic allocation of TTY is not supported")
+}
+
+// Activates VT with chvt.
+func activateTTY(tty int) error {
+	return exec.Command("/usr/bin/chvt", strconv.Itoa(tty)).Run()
+}
+
+// Revokes access to TTY on path, so all file descriptors opened by previous sessions lose access to it.
+func hangupTTY(path string) error {
+	return syscall.Revoke(path)
+}
+
+// Saving of VT state is not supported on BSD.
+func getVtState(f *os.File) (*vtState, error) {
+	return nil, errors.New("Saving of VT state is not supported")
+}
+
+// Restoring of VT state is not supported on BSD.
+func restoreVtState(f *os.File, state *vtState) error {
+	return nil
+}
+
+// Getting of active VT is not supported on BSD.
+func getActiveTTY() (int, error) {
+	return 0, errors.New("Getting of active TTY is not supported")
+}
+
+// Disallocation of VT is not supported on BSD.
+func disallocateTTY(tty int, previous int) error {
+	return errors.New("Disallocation of TTY is not supported")
+}
@@ -0,0 +1,133 @@
+package src
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	pathConsoleTTY = "/dev/tty0"
+
+	ioctlVtOpenQuery   = 0x5600
+	ioctlVtGetState    = 0x5603
+	ioctlVtActivate    = 0x5606
+	ioctlVtWaitActive  = 0x5607
+	ioctlVtDisallocate = 0x5608
+	ioctlKdSetMode     = 0x4B3A
+	ioctlKdGetMode     = 0x4B3B
+	ioctlKdGetKbMode   = 0x4B44
+	ioctlKdSetKbMode   = 0x4B45
+	ioctlTiocVhangup   = 0x5437
+
+	kdText = 0x00
+)
+
+// vtState defines display and keyboard mode of VT.
+type vtState struct {
+	mode   int32
+	kbMode int32
+}
+
+// vtStat defines state of VTs returned by VT_GETSTATE.
+type vtStat struct {
+	active uint16
+	signal uint16
+	state  uint16
+}
+
+// Asks kernel for the first free VT.
+func getFreeTTY() (int, error) {
+	var tty int32
+	if err := consoleIoctl(ioctlVtOpenQuery, uintptr(unsafe.Pointer(&tty))); err != nil {
+		return 0, err
+	}
+	if tty <= 0 {
+		return 0, errors.New("Could not find any free TTY")
+	}
+	return int(tty), nil
+}
+
+// Activates VT and waits, until it becomes active.
+func activateTTY(tty int) error {
+	if err := consoleIoctl(ioctlVtActivate, uintptr(tty)); err != nil {
+		return err
+	}
+	return consoleIoctl(ioctlVtWaitActive, uintptr(tty))
+}
+
+// Calls ioctl on console device.
+func consoleIoctl(request uintptr, arg uintptr) error {
+	f, err := os.OpenFile(pathConsoleTTY, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Hangs up TTY on path, so all file descriptors opened by previous sessions lose access to it.
+func hangupTTY(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// emptty could have the TTY as controlling terminal, so it would be hung up as well
+	signal.Ignore(syscall.SIGHUP)
+	defer signal.Reset(syscall.SIGHUP)
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlTiocVhangup, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Gets display and keyboard mode of VT.
+func getVtState(f *os.File) (*vtState, error) {
+	state := &vtState{}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlKdGetMode, uintptr(unsafe.Pointer(&state.mode))); errno != 0 {
+		return nil, errno
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlKdGetKbMode, uintptr(unsafe.Pointer(&state.kbMode))); errno != 0 {
+		return nil, errno
+	}
+	return state, nil
+}
+
+// Switches VT back to text mode and restores its keyboard mode.
+func restoreVtState(f *os.File, state *vtState) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlKdSetMode, kdText); errno != 0 {
+		return errno
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlKdSetKbMode, uintptr(state.kbMode)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Gets number of currently active VT.
+func getActiveTTY() (int, error) {
+	stat := &vtStat{}
+	if err := consoleIoctl(ioctlVtGetState, uintptr(unsafe.Pointer(stat))); err != nil {
+		return 0, err
+	}
+	return int(stat.active), nil
+}
+
+// Disallocates VT. Active VT could not be disallocated, so previous VT is activated first.
+func disallocateTTY(tty int, previous int) error {
+	if previous > 0 && previous != tty {
+		if err := activateTTY(previous); err != nil {
+			return err
+		}
+	}
+	return consoleIoctl(ioctlVtDisallocate, uintptr(tty))
+}
@@ -0,0 +1,43 @@
+package src
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestHangupTTY(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("TestHangupTTY: hanging up TTY requires root")
+	}
+
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skip("TestHangupTTY: pseudo terminal is not available")
+	}
+	defer ptmx.Close()
+
+	var ptn, unlock uint32
+	syscall.Syscall(syscall.SYS_IOCTL, ptmx.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock)))
+	syscall.Syscall(syscall.SYS_IOCTL, ptmx.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&ptn)))
+	path := "/dev/pts/" + strconv.Itoa(int(ptn))
+
+	f, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := hangupTTY(path); err != nil {
+		t.Fatalf("TestHangupTTY: %v", err)
+	}
+	if _, err := f.Write([]byte("test")); err == nil {
+		t.Error("TestHangupTTY: previously opened TTY should not be writable")
+	}
+
+	if err := hangupTTY(path + "-missing"); err == nil {
+		t.Error("TestHangupTTY: missing TTY should return error")
+	}
+}
@@ -0,0 +1,124 @@
+package src
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	envLoginWorker    = "EMPTTY_LOGIN_WORKER"
+	envLoginWorkerTTY = "EMPTTY_LOGIN_WORKER_TTY"
+
+	workerExitAborted     = 10
+	workerExitInterrupted = 11
+)
+
+// Runs login in worker process. Worker is registered as leader of user's session by PAM or logind,
+// so the leader ends together with the session and emptty stays out of session of each logged in user.
+// It returns true, if login was aborted.
+func runLoginWorker(conf *config, fTTY *os.File) bool {
+	executable, err := os.Executable()
+	if err != nil {
+		log.Print(err)
+		return true
+	}
+
+	worker := exec.Command(executable, os.Args[1:]...)
+	worker.Env = append(os.Environ(), envLoginWorker+"="+strconv.Itoa(failedLoginAttempts), envLoginWorkerTTY+"="+strconv.Itoa(conf.tty))
+	worker.Stdin = fTTY
+	worker.Stdout = fTTY
+	worker.Stderr = fTTY
+	return runWorkerCommand(worker)
+}
+
+// Starts worker command and waits for its result, signals of emptty are passed to the worker.
+// Worker reports count of failed login attempts through the first extra file.
+func runWorkerCommand(worker *exec.Cmd) bool {
+	r, w, err := os.Pipe()
+	if err != nil {
+		log.Print(err)
+		return true
+	}
+	defer r.Close()
+	worker.ExtraFiles = []*os.File{w}
+
+	c := notifyTerminalSignals()
+	defer signal.Stop(c)
+
+	err = worker.Start()
+	w.Close()
+	if err != nil {
+		log.Print(err)
+		return true
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- worker.Wait()
+	}()
+
+	for {
+		select {
+		case s := <-c:
+			log.Print("Catched interrupt signal")
+			atomic.StoreInt32(&interrupted, 1)
+			worker.Process.Signal(s)
+		case err := <-exited:
+			if result, readErr := ioutil.ReadAll(r); readErr == nil {
+				if attempts, convErr := strconv.Atoi(strings.TrimSpace(string(result))); convErr == nil {
+					failedLoginAttempts = attempts
+				}
+			}
+			if err == nil {
+				return false
+			}
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == workerExitInterrupted {
+				atomic.StoreInt32(&interrupted, 1)
+			}
+			return true
+		}
+	}
+}
+
+// Checks, if emptty was started as login worker.
+func isLoginWorker() bool {
+	_, ok := os.LookupEnv(envLoginWorker)
+	return ok
+}
+
+// Runs single login as worker of daemon loop and exits with its result.
+// Count of failed login attempts and TTY selected by daemon are taken over from environment.
+func runWorker() {
+	failedLoginAttempts, _ = strconv.Atoi(os.Getenv(envLoginWorker))
+	tty, _ := strconv.Atoi(os.Getenv(envLoginWorkerTTY))
+	os.Unsetenv(envLoginWorker)
+	os.Unsetenv(envLoginWorkerTTY)
+
+	conf := loadAppConfig()
+	conf.tty = tty
+	loopMode = true
+	// log file was already rotated by daemon
+	if conf.logging == Default {
+		conf.logging = Appending
+	}
+	initLogger(conf)
+
+	aborted := runLogin(conf)
+
+	result := os.NewFile(3, "worker-result")
+	result.WriteString(strconv.Itoa(failedLoginAttempts))
+	result.Close()
+
+	if atomic.LoadInt32(&interrupted) != 0 {
+		os.Exit(workerExitInterrupted)
+	} else if aborted {
+		os.Exit(workerExitAborted)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,34 @@
+package src
+
+import (
+	"os/exec"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunWorkerCommand(t *testing.T) {
+	failedLoginAttempts = 0
+	defer func() {
+		failedLoginAttempts = 0
+		atomic.StoreInt32(&interrupted, 0)
+	}()
+
+	if runWorkerCommand(exec.Command("/bin/sh", "-c", "echo 2 >&3")) {
+		t.Error("TestRunWorkerCommand: successful worker should not be aborted")
+	}
+	if failedLoginAttempts != 2 {
+		t.Errorf("TestRunWorkerCommand: expected 2 failed attempts, got %d", failedLoginAttempts)
+	}
+
+	if !runWorkerCommand(exec.Command("/bin/sh", "-c", "echo 0 >&3; exit "+strconv.Itoa(workerExitAborted))) {
+		t.Error("TestRunWorkerCommand: failed worker should be aborted")
+	}
+	if failedLoginAttempts != 0 || atomic.LoadInt32(&interrupted) != 0 {
+		t.Error("TestRunWorkerCommand: aborted worker should reset attempts and not interrupt")
+	}
+
+	if !runWorkerCommand(exec.Command("/bin/sh", "-c", "exit "+strconv.Itoa(workerExitInterrupted))) || atomic.LoadInt32(&interrupted) == 0 {
+		t.Error("TestRunWorkerCommand: interrupted worker should stop the loop")
+	}
+}
@@ -0,0 +1,64 @@
+package src
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"os"
+	"strings"
+)
+
+const (
+	xauthFamilyLocal = 256
+	xauthCookieName  = "MIT-MAGIC-COOKIE-1"
+	xauthCookieSize  = 16
+)
+
+// Generates new MIT-MAGIC-COOKIE-1 for display and writes it into Xauthority file on path.
+// The file is owned by sysuser and is readable only by its owner.
+func generateXauthority(usr *sysuser, path string, display string) error {
+	cookie := make([]byte, xauthCookieSize)
+	if _, err := rand.Read(cookie); err != nil {
+		return err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+
+	os.Remove(path)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(prepareXauthEntry(hostname, display, cookie)); err != nil {
+		return err
+	}
+	return f.Chown(usr.uid, usr.gid)
+}
+
+// Prepares Xauthority entry of local family in binary format.
+// Each entry consists of family, address, display number, auth name and auth data.
+func prepareXauthEntry(hostname string, display string, cookie []byte) []byte {
+	number := strings.TrimPrefix(display, ":")
+	if strings.Index(number, ".") >= 0 {
+		number = number[:strings.Index(number, ".")]
+	}
+
+	result := make([]byte, 2)
+	binary.BigEndian.PutUint16(result, xauthFamilyLocal)
+	for _, field := range [][]byte{[]byte(hostname), []byte(number), []byte(xauthCookieName), cookie} {
+		result = appendXauthField(result, field)
+	}
+	return result
+}
+
+// Appends field into Xauthority entry prefixed with its length.
+func appendXauthField(entry []byte, field []byte) []byte {
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(field)))
+	entry = append(entry, length...)
+	return append(entry, field...)
+}
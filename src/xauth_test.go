@@ -0,0 +1,52 @@
+package src
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrepareXauthEntry(t *testing.T) {
+	cookie := []byte{0x01, 0x02, 0x03}
+	entry := prepareXauthEntry("host", ":12.0", cookie)
+
+	expected := []byte{0x01, 0x00, 0x00, 0x04, 'h', 'o', 's', 't', 0x00, 0x02, '1', '2', 0x00, 0x12}
+	expected = append(expected, []byte(xauthCookieName)...)
+	expected = append(expected, 0x00, 0x03, 0x01, 0x02, 0x03)
+
+	if !bytes.Equal(entry, expected) {
+		t.Errorf("TestPrepareXauthEntry: unexpected entry %v", entry)
+	}
+}
+
+func TestGenerateXauthority(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emptty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ".Xauthority")
+	ioutil.WriteFile(path, []byte("previous"), 0644)
+
+	u := &sysuser{uid: os.Getuid(), gid: os.Getgid()}
+	if err := generateXauthority(u, path, ":1"); err != nil {
+		t.Fatalf("TestGenerateXauthority: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("TestGenerateXauthority: unexpected file permissions %v", info)
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	hostname, _ := os.Hostname()
+	if len(data) != 2+2+len(hostname)+2+1+2+len(xauthCookieName)+2+xauthCookieSize {
+		t.Errorf("TestGenerateXauthority: unexpected length of Xauthority %d", len(data))
+	}
+	if !bytes.Contains(data, []byte(xauthCookieName)) {
+		t.Error("TestGenerateXauthority: cookie name is missing")
+	}
+}
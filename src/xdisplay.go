@@ -0,0 +1,148 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	pathXLock           = "/tmp/.X%d-lock"
+	pathXSocket         = "/tmp/.X11-unix/X%d"
+	pathXorgLog         = "/var/log/Xorg.%d.log"
+	pathRootlessXorgLog = "/.local/share/xorg/Xorg.%d.log"
+
+	maxXDisplays = 32
+	xorgLogTail  = 10
+
+	// xorgLogVerbosity defines verbosity of Xorg output, it is the same as default verbosity of Xorg log
+	xorgLogVerbosity = 3
+)
+
+// Finds free display for spawning Xorg instance.
+// Display is considered as used, if it has lock file of running process or if its socket accepts connections.
+func getFreeXDisplay() (int, error) {
+	for i := 0; i < maxXDisplays; i++ {
+		if isXDisplayFree(i) {
+			return i, nil
+		}
+	}
+	return -1, errors.New("Could not find any free X display")
+}
+
+// Checks, if X display is not used by any running server.
+func isXDisplayFree(display int) bool {
+	socket := fmt.Sprintf(pathXSocket, display)
+	return !isXLockActive(fmt.Sprintf(pathXLock, display)) && !isSocketActive(socket) && !isSocketActive("@"+socket)
+}
+
+// Checks, if lock file exists and belongs to running process.
+// Lock file, that could not be read or parsed, is considered as active.
+func isXLockActive(path string) bool {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return !os.IsNotExist(err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return true
+	}
+	return isProcessAlive(pid)
+}
+
+// Checks, if process with pid exists.
+func isProcessAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// Checks, if unix socket on path accepts connections. Path starting with '@' represents abstract socket.
+func isSocketActive(path string) bool {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Starts Xorg and waits, until it signals readiness by SIGUSR1 or until timeout expires.
+// If timeout is not positive, it waits without limit.
+// Returned channel receives result of Xorg process, when it finishes.
+func startXorg(xorg *exec.Cmd, display int, timeout time.Duration) (chan error, error) {
+	// Xorg sends SIGUSR1 to its parent, only if it inherits SIGUSR1 as ignored.
+	ready := make(chan os.Signal, 1)
+	signal.Ignore(syscall.SIGUSR1)
+	err := xorg.Start()
+	signal.Notify(ready, syscall.SIGUSR1)
+	defer signal.Stop(ready)
+	if err != nil {
+		return nil, err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- xorg.Wait()
+	}()
+
+	// Xorg could become ready before SIGUSR1 was handled
+	socket := fmt.Sprintf(pathXSocket, display)
+	if isSocketActive(socket) || isSocketActive("@"+socket) {
+		return exited, nil
+	}
+
+	// Xorg started as user is not permitted to signal root process, its socket needs to be checked instead
+	var poll <-chan time.Time
+	if xorg.SysProcAttr != nil && xorg.SysProcAttr.Credential != nil && xorg.SysProcAttr.Credential.Uid != uint32(os.Getuid()) {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+	for {
+		select {
+		case <-ready:
+			return exited, nil
+		case <-poll:
+			if isSocketActive(socket) || isSocketActive("@"+socket) {
+				return exited, nil
+			}
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("exited")
+			}
+			return nil, fmt.Errorf("Xorg failed to start: %v", err)
+		case <-deadline:
+			xorg.Process.Signal(os.Interrupt)
+			<-exited
+			return nil, fmt.Errorf("Xorg did not start in %v", timeout)
+		}
+	}
+}
+
+// Gets last lines of Xorg log for display, or empty string if log could not be read.
+func getXorgLogTail(path string, count int) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > count {
+		lines = lines[len(lines)-count:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,112 @@
+package src
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsXLockActive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emptty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ".X0-lock")
+	if isXLockActive(path) {
+		t.Error("TestIsXLockActive: missing lock file should not be active")
+	}
+
+	ioutil.WriteFile(path, []byte("      "+strconv.Itoa(os.Getpid())+"\n"), 0444)
+	if !isXLockActive(path) {
+		t.Error("TestIsXLockActive: lock file of running process should be active")
+	}
+
+	cmd := exec.Command("true")
+	cmd.Run()
+	ioutil.WriteFile(path, []byte(strconv.Itoa(cmd.Process.Pid)+"\n"), 0444)
+	if isXLockActive(path) {
+		t.Error("TestIsXLockActive: lock file of finished process should not be active")
+	}
+
+	ioutil.WriteFile(path, []byte("garbage"), 0444)
+	if !isXLockActive(path) {
+		t.Error("TestIsXLockActive: unparsable lock file should be active")
+	}
+}
+
+func TestIsSocketActive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emptty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "X0")
+	if isSocketActive(path) {
+		t.Error("TestIsSocketActive: missing socket should not be active")
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isSocketActive(path) {
+		t.Error("TestIsSocketActive: listening socket should be active")
+	}
+
+	l.Close()
+	if isSocketActive(path) {
+		t.Error("TestIsSocketActive: closed socket should not be active")
+	}
+}
+
+func TestStartXorg(t *testing.T) {
+	xorg := exec.Command("/bin/sh", "-c", "sleep 0.5; kill -USR1 $PPID; exec sleep 10")
+	exited, err := startXorg(xorg, maxXDisplays, 5*time.Second)
+	if err != nil {
+		t.Fatalf("TestStartXorg: readiness was not handled: %v", err)
+	}
+	xorg.Process.Kill()
+	<-exited
+
+	xorg = exec.Command("/bin/sh", "-c", "exit 1")
+	if _, err = startXorg(xorg, maxXDisplays, 5*time.Second); err == nil {
+		t.Error("TestStartXorg: failed process should return error")
+	}
+
+	xorg = exec.Command("/bin/sh", "-c", "exec sleep 10")
+	if _, err = startXorg(xorg, maxXDisplays, 100*time.Millisecond); err == nil {
+		t.Error("TestStartXorg: timeout should return error")
+	}
+
+	xorg = exec.Command("/bin/sh", "-c", "sleep 0.2; exit 1")
+	if _, err = startXorg(xorg, maxXDisplays, 0); err == nil || strings.Contains(err.Error(), "did not start") {
+		t.Errorf("TestStartXorg: zero timeout should wait for process, got %v", err)
+	}
+}
+
+func TestGetXorgLogTail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emptty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "Xorg.0.log")
+	if getXorgLogTail(path, 2) != "" {
+		t.Error("TestGetXorgLogTail: missing log should return empty string")
+	}
+
+	ioutil.WriteFile(path, []byte("line1\nline2\nline3\n"), 0644)
+	if tail := getXorgLogTail(path, 2); tail != "line2\nline3" {
+		t.Errorf("TestGetXorgLogTail: unexpected tail '%s'", tail)
+	}
+}
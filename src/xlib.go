@@ -8,7 +8,6 @@ package src
 import "C"
 import (
 	"errors"
-	"time"
 	"unsafe"
 )
 
@@ -26,13 +25,10 @@ func (c *xdisplay) openXDisplay() error {
 	}
 	displayName := C.CString(c.dispName)
 	defer C.free(unsafe.Pointer(displayName))
-	for i := 0; i < 50; i++ {
-		d := C.XOpenDisplay(displayName)
-		if d != nil {
-			c.disp = d
-			return nil
-		}
-		time.Sleep(50 * time.Millisecond)
+	d := C.XOpenDisplay(displayName)
+	if d == nil {
+		return errors.New("Could not open X Display")
 	}
-	return errors.New("Could not open X Display")
+	c.disp = d
+	return nil
 }
@@ -2,10 +2,6 @@
 
 package src
 
-import (
-	"time"
-)
-
 const tagXlib = "noxlib"
 
 type xdisplay struct {
@@ -13,14 +9,7 @@ type xdisplay struct {
 	dispName string
 }
 
-// Slows down start by waiting to create X lock file
+// Does nothing, readiness of Xorg is already handled by its SIGUSR1 signal.
 func (c *xdisplay) openXDisplay() error {
-	for i := 0; i < 50; i++ {
-		if fileExists("/tmp/.X11-unix/X" + c.dispName[1:]) {
-			break
-		} else {
-			time.Sleep(10 * time.Millisecond)
-		}
-	}
 	return nil
 }